@@ -0,0 +1,248 @@
+package main
+
+import (
+	"io/fs"         // Used for walking the asset directories
+	"net/url"       // Used for decoding references
+	"os"            // Used for reading stylesheets
+	"path/filepath" // Used for building file paths
+	"sort"          // Used for reporting files in a stable order
+	"strings"       // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// The files of the built site (slash-separated and relative to dist/), each
+// mapped to the local files that it refers to. Only files that can be reached
+// from a page are in the graph.
+type refGraph map[string][]string
+
+// Builds the graph of everything referenced by docs, the built pages, where
+// docs[i] is written to dests[i]. Stylesheets are followed through their
+// url()s and @imports.
+func buildRefGraph(docs []*lib.HTMLNode, dests []string) refGraph {
+	var graph = make(refGraph)
+	var queue []string
+	for i, doc := range docs {
+		from, err := filepath.Rel(distPath(), dests[i])
+		if err != nil {
+			from = dests[i]
+		}
+		graph[filepath.ToSlash(from)] = collectRefs(doc, dests[i])
+		queue = append(queue, graph[filepath.ToSlash(from)]...)
+	}
+
+	for len(queue) > 0 {
+		var asset string = queue[0]
+		queue = queue[1:]
+		if _, ok := graph[asset]; ok {
+			continue
+		}
+		graph[asset] = nil
+		if filepath.Ext(asset) != ".css" {
+			continue
+		}
+		// Stylesheets are copied as-is, so their references are resolved
+		// from where they end up in dist/.
+		data, err := os.ReadFile(devPath(filepath.FromSlash(asset)))
+		if err != nil {
+			continue
+		}
+		for _, url := range lib.CSSURLs(string(data)) {
+			if ref, ok := resolveRef(url,
+				distPath(filepath.FromSlash(asset))); ok {
+				graph[asset] = append(graph[asset], ref)
+			}
+		}
+		queue = append(queue, graph[asset]...)
+	}
+	return graph
+}
+
+// Builds the graph of docs, the built versions of pages, along with
+// dist/index.html if it's written by hand rather than built from dev/pages.
+func buildSiteGraph(pages []page, docs []*lib.HTMLNode) refGraph {
+	var dests []string
+	for _, p := range pages {
+		dests = append(dests, p.dest)
+	}
+	if !contains(dests, distPath("index.html")) {
+		data, err := os.ReadFile(distPath("index.html"))
+		if err == nil {
+			docs = append(docs, lib.ParseHTML(string(data)))
+			dests = append(dests, distPath("index.html"))
+		}
+	}
+	return buildRefGraph(docs, dests)
+}
+
+// Returns every file that something in the graph refers to, sorted.
+func (graph refGraph) referenced() []string {
+	var files []string
+	for _, refs := range graph {
+		for _, ref := range refs {
+			if !contains(files, ref) {
+				files = append(files, ref)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Returns every file within the asset directories of dev/ (dev/imgs,
+// dev/scripts, and dev/styles) that nothing in the graph refers to, as paths
+// relative to dev/. Files that are kept anyway (see keepAsset()) aren't
+// unused.
+func (graph refGraph) unusedAssets() ([]string, error) {
+	unreferenced, err := graph.unreferencedAssets()
+	var unused []string
+	for _, asset := range unreferenced {
+		if !keepAsset(asset) {
+			unused = append(unused, asset)
+		}
+	}
+	return unused, err
+}
+
+// Returns every file that a build copies into dist/: those that something in
+// the graph refers to, along with those that are kept anyway (see
+// keepAsset()), sorted.
+func (graph refGraph) copiedAssets() ([]string, error) {
+	var files []string = graph.referenced()
+	unreferenced, err := graph.unreferencedAssets()
+	for _, asset := range unreferenced {
+		if keepAsset(asset) {
+			files = append(files, asset)
+		}
+	}
+	sort.Strings(files)
+	return files, err
+}
+
+// Returns whether asset (relative to dev/) is copied into dist/ even though
+// no page refers to it: either because "build.assets" in webes.json lists it,
+// or because the unused-asset rule is turned off, since it's then up to the
+// user which files are needed (as with a file that only a script loads).
+func keepAsset(asset string) bool {
+	return project.config.Validate.Rules["unused-asset"] == "off" ||
+		safelisted(project.config.Build.Assets, asset)
+}
+
+// Returns every file within the asset directories of dev/ that nothing in the
+// graph refers to, as paths relative to dev/.
+func (graph refGraph) unreferencedAssets() ([]string, error) {
+	var referenced []string = graph.referenced()
+	var unreferenced []string
+	for _, dir := range assetDirs {
+		err := filepath.WalkDir(devPath(dir),
+			func(path string, d fs.DirEntry, err error) error {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					return nil
+				}
+				rel, err := filepath.Rel(devPath(), path)
+				if err != nil {
+					return err
+				}
+				if !contains(referenced, filepath.ToSlash(rel)) {
+					unreferenced = append(unreferenced, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return unreferenced, nil
+}
+
+// The <meta> tags whose content is an image.
+var shareImageTags = []string{"og:image", "twitter:image"}
+
+// Returns every local file (relative to dist/) that doc, which is written to
+// dest, refers to: through src and href attributes, srcset candidates, the
+// images of Open Graph and Twitter Card tags, and url()s within style
+// attributes and <style> elements.
+func collectRefs(doc *lib.HTMLNode, dest string) []string {
+	var refs []string
+	add := func(ref string) {
+		if asset, ok := resolveRef(strings.TrimSpace(ref), dest); ok {
+			refs = append(refs, asset)
+		}
+	}
+
+	doc.Walk(func(n *lib.HTMLNode) bool {
+		if n.Type != lib.HTMLElementNode {
+			return true
+		}
+		for _, attr := range n.Attrs {
+			switch strings.ToLower(attr.Name) {
+			case "src", "href", "poster":
+				add(attr.Value)
+			case "srcset":
+				// `small.png 480w, large.png 1080w`
+				for _, candidate := range strings.Split(attr.Value, ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						add(fields[0])
+					}
+				}
+			case "style":
+				for _, url := range lib.CSSURLs(attr.Value) {
+					add(url)
+				}
+			}
+		}
+		// Images shown when the page is shared, as filled in from its front
+		// matter
+		if n.Is("meta") && (contains(shareImageTags, n.AttrValue("property")) ||
+			contains(shareImageTags, n.AttrValue("name"))) {
+			add(n.AttrValue("content"))
+		}
+		if n.Is("style") {
+			for _, url := range lib.CSSURLs(n.Text()) {
+				add(url)
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// Resolves ref, as found in the file written to dest, to a slash-separated
+// path relative to dist/. Returns false for anything that isn't a local file
+// within dist/ (URLs, anchors, data: URIs, ...).
+func resolveRef(ref string, dest string) (string, bool) {
+	if idx := strings.IndexAny(ref, "?#"); idx != -1 {
+		ref = ref[:idx]
+	}
+	// Absolute URLs within the site itself (as set in webes.json) are local
+	if project.config.Site.URL != "" &&
+		strings.HasPrefix(ref, project.config.Site.URL+"/") {
+		ref = strings.TrimPrefix(ref, project.config.Site.URL)
+	}
+	if ref == "" || strings.HasPrefix(ref, "//") || strings.Contains(ref, ":") {
+		return "", false
+	}
+	// URLs are percent-encoded, e.g. "my%20photo.png", while files aren't
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+
+	var target string
+	if strings.HasPrefix(ref, "/") {
+		target = filepath.Join(distPath(), filepath.FromSlash(ref))
+	} else {
+		target = filepath.Join(filepath.Dir(dest), filepath.FromSlash(ref))
+	}
+	rel, err := filepath.Rel(distPath(), target)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"webes/lib"
+)
+
+func TestResolveRef(t *testing.T) {
+	project = &Project{root: "site", dist: filepath.Join("site", "dist"),
+		config: defaultConfig()}
+	project.config.Site.URL = "https://example.com"
+	var page string = distPath("pages", "blog", "post.html")
+	var tests = []struct {
+		ref  string
+		want string // "" if it isn't a local file
+	}{
+		{"../../imgs/a.png", "imgs/a.png"},
+		{"/styles/style.css?v=2#top", "styles/style.css"},
+		{"../../imgs/my%20photo.png", "imgs/my photo.png"},
+		{"https://example.com/imgs/a.png", "imgs/a.png"},
+		{"photo.png", "pages/blog/photo.png"},
+		{"https://elsewhere.com/a.png", ""},
+		{"//cdn.example.com/a.js", ""},
+		{"data:image/png;base64,AAAA", ""},
+		{"#section", ""},
+		{"../../../outside.png", ""},
+	}
+	for _, test := range tests {
+		got, ok := resolveRef(test.ref, page)
+		if !ok {
+			got = ""
+		}
+		if got != test.want {
+			t.Errorf("resolveRef(%q) = %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
+
+func TestCollectRefs(t *testing.T) {
+	project = &Project{root: "site", dist: filepath.Join("site", "dist"),
+		config: defaultConfig()}
+	// As the Markdown renderer writes `![me](<imgs/my photo.png>)`
+	html, _ := lib.MarkdownToHTML("![me](<imgs/my photo.png>)")
+	var doc *lib.HTMLNode = lib.ParseHTML(html + "<link href=\"style.css\">" +
+		"<img srcset=\"a.png 1x, b%2Bc.png 2x\">" +
+		"<div style=\"background: url('d.png')\"></div>")
+	var want = []string{"imgs/my photo.png", "style.css", "a.png", "b+c.png",
+		"d.png"}
+	if got := collectRefs(doc, distPath("index.html")); !reflect.DeepEqual(
+		got, want) {
+		t.Errorf("collectRefs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"io/fs"         // Used for walking dev/pages
+	"os"            // Used for reading and writing files
+	"path/filepath" // Used for building file paths
+	"sort"          // Used for building components in a stable order
+	"strconv"       // Used for number to string conversions
+	"strings"       // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// A page within dev/pages and the path its built version is written to.
+type page struct {
+	src  string
+	dest string
+}
+
+// The directories of dev/ whose files are copied into dist/ when (and only
+// when) a built page references them.
+var assetDirs = []string{"imgs", "scripts", "styles"}
+
+// What the previous build produced, so that the next one (when watching
+// dev/ for changes) only has to write what changed since.
+type buildCache struct {
+	deps    map[string][]string // page src -> the files it's built from
+	outputs map[string]string   // page src -> the path it was written to
+	styles  map[string]string   // component path -> its style, as inlined
+	scripts map[string]string   // component path -> its script, as inlined
+	assets  []string            // files (relative to dist/) that were copied
+}
+
+// Compiles every page in dev/pages, along with the components, scripts,
+// styles, and images that it uses, into a finished static site in dist/.
+// With --watch, dev/ is then watched for changes, and the pages and files
+// affected by each change are rebuilt. With --page, only that page is
+// written, leaving the rest of dist/ as it is.
+// Callable via `webes build [--watch] [--page=about.html]`
+func webes_build(call *commandCall) error {
+	var watch bool = call.boolFlag("watch")
+	var only string
+	if name := call.stringFlag("page"); name != "" {
+		p, err := findPage(name)
+		if err != nil {
+			return err
+		}
+		only = p.src
+	}
+
+	lib.FmtPrint("Building Project", "header", "info")
+	var cache = &buildCache{}
+	err := buildProject(cache, nil, only)
+	if !watch {
+		return err
+	}
+	if err != nil {
+		lib.FmtPrint(err.Error(), "error")
+	}
+
+	lib.FmtPrint("Watching dev/ for changes (press Ctrl+C to stop)", "info")
+	watchDir(devPath(), watchInterval, watchQuiet, func(changed []string) {
+		if err := buildProject(cache, changed, only); err != nil {
+			lib.FmtPrint(err.Error(), "error")
+		}
+	})
+	return nil
+}
+
+// Does the work of webes_build(), returning any error that stopped the build.
+// The first build with a given cache builds everything from scratch. After
+// that, changed holds the files within dev/ that changed since the previous
+// build, and only the pages and files affected by them are written. If only
+// is given, it's the source of the one page that's written, though every page
+// is still expanded so that what's left out of it is the same as in a full
+// build.
+func buildProject(cache *buildCache, changed []string, only string) error {
+	var full bool = cache.deps == nil
+
+	components, err := loadComponents()
+	if err != nil {
+		return err
+	}
+	var includeGraph *depGraph = newDepGraph(components)
+	if cycle := includeGraph.findCycle(); cycle != nil {
+		return includeGraph.cycleError(cycle)
+	}
+
+	// Warn about whatever webes_validate would report as unused
+	var names []string
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := components[name]
+		if !full && !contains(changed, c.path) {
+			continue
+		}
+		for _, fd := range validateComponent(c) {
+			d, ok := project.config.applyRule(fd.diagnostic())
+			if !ok {
+				continue
+			}
+			if d.Severity == "error" {
+				return &webesError{Diagnostic: d}
+			}
+			lib.PrintDiagnostic(d)
+		}
+	}
+
+	pages, err := findPages()
+	if err != nil {
+		return err
+	}
+
+	// Every page is expanded before any of them are written, so that the
+	// styles and scripts that no page uses can be left out of all of them.
+	var docs []*lib.HTMLNode
+	var usedBy = make(map[*lib.HTMLNode][]*component)
+	var used []*component
+	var refs []string
+	var pageFiles []string
+	for _, p := range pages {
+		src, err := readPage(p)
+		if err != nil {
+			return err
+		}
+		pageFiles = append(pageFiles, src.file)
+		// Props are checked up front so that problems with them point at
+		// the right place, even in Markdown pages
+		for _, d := range checkUsage(parsePage(src, components),
+			components, p.src, src.file, src.lines.Pos) {
+			if d, _ = project.config.applyRule(d); d.Severity == "error" {
+				return &webesError{Diagnostic: d}
+			}
+		}
+		doc, pageUsed, err := expandPage(src, components)
+		if err != nil {
+			return inFile(err, p.src)
+		}
+		if placeholder, where, ok := findPlaceholder(doc); ok {
+			return inFile(newError("E206", "the placeholder %s (in %s) would "+
+				"end up in %s, fill it in (e.g. through the page's front "+
+				"matter)", placeholder, where, project.rel(p.dest)), p.src)
+		}
+		docs = append(docs, doc)
+		usedBy[doc] = pageUsed
+		for _, c := range pageUsed {
+			if !containsComponent(used, c) {
+				used = append(used, c)
+			}
+		}
+		refs = append(refs, collectRefs(doc, p.dest)...)
+	}
+
+	// Leave out the selectors that match nothing, and the functions that
+	// nothing calls (unless webes.json turns that off)
+	var styles = make(map[*component]string)
+	var removedSelectors int = 0
+	for _, c := range used {
+		style := strings.TrimSpace(c.style)
+		if style == "" {
+			continue
+		}
+		style = scopeStyle(style, scopeAttr(c))
+		if !project.config.Build.Prune {
+			styles[c] = style
+			continue
+		}
+		style, removed := pruneStyle(style, docs, componentSafelist(c))
+		styles[c] = style
+		removedSelectors += removed
+	}
+	var scripts = make(map[*component]string)
+	var removedFunctions int = 0
+	if project.config.Build.Prune {
+		scripts, removedFunctions = pruneScripts(used, pageFiles, refs)
+	} else {
+		for _, c := range used {
+			scripts[c] = c.script
+		}
+	}
+	if full && (removedSelectors > 0 || removedFunctions > 0) {
+		lib.FmtPrint("Left out "+strconv.Itoa(removedSelectors)+
+			" unused selector(s) and "+strconv.Itoa(removedFunctions)+
+			" unused function(s)", "info")
+	}
+
+	if full && only == "" {
+		if err := cleanDist(); err != nil {
+			return err
+		}
+	}
+	var next = &buildCache{
+		deps:    make(map[string][]string),
+		outputs: make(map[string]string),
+		styles:  make(map[string]string),
+		scripts: make(map[string]string),
+	}
+	for _, c := range used {
+		next.styles[c.path] = styles[c]
+		next.scripts[c.path] = scripts[c]
+	}
+
+	// A page is rebuilt when it, or any component it uses, changed, as well
+	// as when what's inlined for one of its components did (as that depends
+	// on every page using the component).
+	var rebuilt int = 0
+	for i, p := range pages {
+		var deps = []string{p.src}
+		var affected bool = full || cache.outputs[p.src] != p.dest
+		for _, c := range usedBy[docs[i]] {
+			deps = append(deps, c.path)
+			if !full && (next.styles[c.path] != cache.styles[c.path] ||
+				next.scripts[c.path] != cache.scripts[c.path]) {
+				affected = true
+			}
+		}
+		for _, dep := range deps {
+			if contains(changed, dep) {
+				affected = true
+			}
+		}
+		next.deps[p.src] = deps
+		next.outputs[p.src] = p.dest
+
+		inlineComponents(docs[i], usedBy[docs[i]], styles, scripts)
+		if affected && (only == "" || p.src == only) {
+			err := writeFile(p.dest, []byte(lib.RenderHTML(docs[i])))
+			if err != nil {
+				return err
+			}
+			rebuilt++
+		}
+	}
+	// Pages that no longer exist take their built versions with them
+	for src, dest := range cache.outputs {
+		if _, ok := next.outputs[src]; !ok {
+			if err := removeFile(dest); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only the files that the built pages refer to, directly or through their
+	// stylesheets, are copied over
+	var graph refGraph = buildSiteGraph(pages, docs)
+	next.assets, err = graph.copiedAssets()
+	if err != nil {
+		return readError(devPath(), err)
+	}
+	var copies []string
+	for _, asset := range next.assets {
+		if full || !contains(cache.assets, asset) ||
+			contains(changed, devPath(filepath.FromSlash(asset))) {
+			copies = append(copies, asset)
+		}
+	}
+	if err := copyAssets(copies); err != nil {
+		return err
+	}
+	for _, asset := range cache.assets {
+		dir := strings.SplitN(asset, "/", 2)[0]
+		if contains(assetDirs, dir) && !contains(next.assets, asset) {
+			err := removeFile(distPath(filepath.FromSlash(asset)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	*cache = *next
+
+	if !full {
+		lib.FmtPrint("Rebuilt "+strconv.Itoa(rebuilt)+" page(s) and copied "+
+			strconv.Itoa(len(copies))+" file(s) after "+
+			strconv.Itoa(len(changed))+" change(s) in dev/", "info")
+		return nil
+	}
+	if unused, err := graph.unusedAssets(); err == nil && len(unused) > 0 {
+		lib.FmtPrint("Left out "+strconv.Itoa(len(unused))+
+			" unused file(s)", "info")
+	}
+	lib.FmtPrint("Built "+strconv.Itoa(rebuilt)+" page(s) into dist/",
+		"info")
+	return nil
+}
+
+// Finds every *.html and *.md file within dev/pages. dev/pages/index.html
+// (or index.md) is built to dist/index.html, everything else keeps its path
+// within dist/pages, with Markdown pages ending in .html as well.
+func findPages() ([]page, error) {
+	var pages []page
+	var srcs = make(map[string]string) // dest -> the page built to it
+
+	err := filepath.WalkDir(devPath("pages"),
+		func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			var ext string = filepath.Ext(path)
+			if d.IsDir() || ext != ".html" && ext != ".md" {
+				return nil
+			}
+			rel, err := filepath.Rel(devPath("pages"), path)
+			if err != nil {
+				return err
+			}
+			rel = strings.TrimSuffix(rel, ext) + ".html"
+			var dest string = distPath("pages", rel)
+			if rel == "index.html" {
+				dest = distPath("index.html")
+			}
+			if other, ok := srcs[dest]; ok {
+				return newError("E209", "pages %s and %s would both be built "+
+					"to %s",
+					project.rel(other), project.rel(path), project.rel(dest))
+			}
+			srcs[dest] = path
+			pages = append(pages, page{src: path, dest: dest})
+			return nil
+		})
+	if err != nil && errorCode(err) == "" {
+		err = readError(devPath("pages"), err)
+	}
+	return pages, err
+}
+
+// Returns the page within dev/pages that name refers to: its path relative to
+// dev/pages, with or without its extension (e.g. "blog/post.md" or
+// "blog/post"), or the path of its file.
+func findPage(name string) (page, error) {
+	pages, err := findPages()
+	if err != nil {
+		return page{}, err
+	}
+	abs, _ := filepath.Abs(name)
+	for _, p := range pages {
+		var rel string = pageName(p)
+		if name == rel || name == strings.TrimSuffix(rel, filepath.Ext(rel)) ||
+			abs == p.src {
+			return p, nil
+		}
+	}
+	return page{}, newError("E305", "page \"%s\" not found in dev/pages",
+		name)
+}
+
+// Returns the name of p, i.e. its path relative to dev/pages, e.g.
+// "blog/post.md".
+func pageName(p page) string {
+	rel, err := filepath.Rel(devPath("pages"), p.src)
+	if err != nil {
+		return p.src
+	}
+	return filepath.ToSlash(rel)
+}
+
+// The source of a page, as HTML, along with where it came from.
+type pageSource struct {
+	html  string
+	meta  frontMatter              // read from the start of the page's file
+	file  string                   // the page's file, as read
+	lines *lib.LineIndex           // of file
+	pos   func(offset int) lib.Pos // maps offsets within html to ones within file
+}
+
+// Reads the page p, splitting off its front matter, and compiling it from
+// Markdown first if it's a .md file.
+func readPage(p page) (pageSource, error) {
+	data, err := os.ReadFile(p.src)
+	if err != nil {
+		return pageSource{}, readError(p.src, err)
+	}
+	var src = pageSource{file: string(data)}
+	meta, start, err := parseFrontMatter(src.file)
+	if err != nil {
+		return pageSource{}, inFile(withCode("E207", err), p.src)
+	}
+	src.meta = meta.withSite(p)
+	src.html = src.file[start:]
+	src.lines = lib.NewLineIndex(src.file)
+	src.pos = func(offset int) lib.Pos {
+		return src.lines.Pos(start + offset)
+	}
+	if filepath.Ext(p.src) != ".md" {
+		return src, nil
+	}
+
+	// The lines of the Markdown are counted from the end of the front matter
+	html, lines := lib.MarkdownToHTML(src.file[start:])
+	var skipped int = src.lines.Pos(start).Line - 1
+	for i := range lines {
+		lines[i] += skipped
+	}
+	var htmlLines *lib.LineIndex = lib.NewLineIndex(html)
+	src.html = html
+	src.pos = func(offset int) lib.Pos {
+		var pos lib.Pos = htmlLines.Pos(offset)
+		if pos.Line > len(lines) {
+			return src.lines.Pos(len(src.file))
+		}
+		// HTML that was copied over from the Markdown (component tags and
+		// the like) can be found on its line, everything else points at the
+		// start of the line that it came from
+		var line string = src.lines.Line(lines[pos.Line-1])
+		var start int = src.lines.Offset(lines[pos.Line-1])
+		var rest string = html[offset : htmlLines.Offset(pos.Line)+
+			len(htmlLines.Line(pos.Line))]
+		if end := strings.IndexByte(rest, '>'); end != -1 {
+			rest = rest[:end+1]
+		}
+		if idx := strings.Index(line, rest); rest != "" && idx != -1 {
+			return src.lines.Pos(start + idx)
+		}
+		return src.lines.Pos(start + len(line) - len(strings.TrimLeft(line,
+			" \t")))
+	}
+	return src, nil
+}
+
+// Parses src, the source of a page, with the position of every element and
+// attribute pointing into the page's file (src.file). Pages that are just
+// content, without an <html> element or a <webes-layout> of their own, are
+// wrapped in the layout their front matter names, or else in the default
+// layout (dev/layouts/default.webes) if there is one.
+func parsePage(src pageSource, components map[string]*component) *lib.HTMLNode {
+	var doc *lib.HTMLNode = lib.ParseHTML(src.html)
+	doc.Walk(func(n *lib.HTMLNode) bool {
+		n.Pos = src.pos(n.Pos.Offset)
+		for _, attr := range n.Attrs {
+			attr.Pos = src.pos(attr.Pos.Offset)
+			attr.ValuePos = src.pos(attr.ValuePos.Offset)
+		}
+		return true
+	})
+	if _, ok := doc.Find("html"); ok {
+		return doc
+	}
+	if _, ok := doc.Find("webes-layout"); ok {
+		return doc
+	}
+	var name string = src.meta.layout
+	if name == "" {
+		name = "default"
+		if _, ok := components[componentKey(layoutPrefix+name)]; !ok {
+			return doc
+		}
+	}
+	var layout = &lib.HTMLNode{Type: lib.HTMLElementNode,
+		Name: "webes-layout", EndTag: true,
+		Pos: src.lines.Pos(src.lines.Offset(src.meta.layoutLine))}
+	layout.SetAttr("name", name)
+	// The name points at where the front matter gives it, so that problems
+	// with the layout are shown there
+	if src.meta.layoutLine > 0 {
+		var line string = src.lines.Line(src.meta.layoutLine)
+		if idx := strings.LastIndex(line, name); idx != -1 {
+			attr, _ := layout.Attr("name")
+			attr.ValuePos = src.lines.Pos(layout.Pos.Offset + idx)
+		}
+	}
+	for _, child := range doc.Children {
+		layout.AppendChild(child)
+	}
+	doc.Children = nil
+	doc.AppendChild(layout)
+	return doc
+}
+
+// Expands the layout and components used by the page src, and fills in its
+// <head> from its front matter. Returns the page along with every component
+// (and layout) that it uses.
+func expandPage(src pageSource,
+	components map[string]*component) (*lib.HTMLNode, []*component, error) {
+	var doc *lib.HTMLNode = parsePage(src, components)
+	used, err := expandIncludes(doc, components)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := applyFrontMatter(doc, src.meta); err != nil {
+		return nil, nil, err
+	}
+	return doc, used, nil
+}
+
+// Inlines the styles and scripts (as given by styles and scripts, already
+// pruned) of the components in used into doc, each scoped to the component it
+// belongs to.
+func inlineComponents(doc *lib.HTMLNode, used []*component,
+	styles map[*component]string, scripts map[*component]string) {
+	var styleText, scriptText strings.Builder
+	for _, c := range used {
+		style := strings.TrimSpace(styles[c])
+		if style != "" {
+			styleText.WriteString("/* " + c.name + " */\n" + style + "\n")
+		}
+		script := strings.TrimSpace(scripts[c])
+		if script != "" {
+			script = strings.TrimSpace(scopeScript(c, script))
+			scriptText.WriteString("/* " + c.name + " */\n" + script + "\n")
+		}
+	}
+
+	// Styles go at the end of <head>, and scripts at the end of <body>. Pages
+	// without them get the styles at the very start and the scripts at the
+	// very end instead.
+	if styleText.Len() > 0 {
+		var style = rawTextElement("style", "\n"+styleText.String())
+		if head, ok := doc.Find("head"); ok {
+			head.AppendChild(style)
+			head.AppendChild(&lib.HTMLNode{Type: lib.HTMLTextNode, Data: "\n"})
+		} else {
+			doc.Children = append([]*lib.HTMLNode{style}, doc.Children...)
+			style.Parent = doc
+		}
+	}
+	if scriptText.Len() > 0 {
+		var script = rawTextElement("script", "\n"+scriptText.String())
+		if body, ok := doc.Find("body"); ok {
+			body.AppendChild(script)
+			body.AppendChild(&lib.HTMLNode{Type: lib.HTMLTextNode, Data: "\n"})
+		} else {
+			doc.AppendChild(script)
+		}
+	}
+}
+
+// Returns a new <name> element, e.g. a <style> or <script>, containing text.
+func rawTextElement(name string, text string) *lib.HTMLNode {
+	var el = &lib.HTMLNode{Type: lib.HTMLElementNode, Name: name, EndTag: true}
+	el.AppendChild(&lib.HTMLNode{Type: lib.HTMLTextNode, Data: text})
+	return el
+}
+
+// Copies every referenced asset within the asset directories from dev/ into
+// the same place in dist/.
+func copyAssets(assets []string) error {
+	var copied []string
+	for _, asset := range assets {
+		dir := strings.SplitN(asset, "/", 2)[0]
+		if !contains(assetDirs, dir) || contains(copied, asset) {
+			continue
+		}
+		copied = append(copied, asset)
+
+		data, err := os.ReadFile(devPath(filepath.FromSlash(asset)))
+		if err != nil {
+			lib.FmtPrint("Referenced file dev/"+asset+" could not be read: "+
+				err.Error(), "warning")
+			continue
+		}
+		if err := writeFile(distPath(filepath.FromSlash(asset)),
+			data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes everything that a previous build wrote to dist/. dist/index.html is
+// left alone, as it's either overwritten or written by hand.
+func cleanDist() error {
+	for _, dir := range append([]string{"pages"}, assetDirs...) {
+		err := os.RemoveAll(distPath(dir))
+		if err != nil {
+			return writeError(distPath(dir), err)
+		}
+		err = os.MkdirAll(distPath(dir), 0755)
+		if err != nil {
+			return writeError(distPath(dir), err)
+		}
+	}
+	return nil
+}
+
+// Removes the file at path, if there is one.
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return writeError(path, err)
+	}
+	return nil
+}
+
+// Writes data to path, creating any missing parent directories.
+func writeFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return writeError(filepath.Dir(path), err)
+	}
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return writeError(path, err)
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"  // Used for telling errors apart
+	"flag"    // Used for parsing command options
+	"fmt"     // Used for printing usage
+	"io"      // Used for silencing the flag package's own output
+	"os"      // Used for printing errors
+	"sort"    // Used for listing commands in order
+	"strconv" // Used for number to string conversions
+	"strings" // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// The structure of a command that the user can execute.
+type Command struct {
+	function    func(call *commandCall) error
+	description string
+	args        []commandArg  // positional arguments, in order
+	flags       []commandFlag // flags, which go before the arguments
+	examples    []string      // shown by `webes help <command>`
+	// Whether the command works on an existing project, which is then found
+	// by walking up from the working directory
+	needsProject bool
+	// Whether the command doesn't use the project at all (e.g. help), so
+	// that it works even if webes.json is broken
+	standalone bool
+	hidden     bool // left out of `webes help`, e.g. __complete
+}
+
+// A positional argument of a command.
+type commandArg struct {
+	name        string
+	description string
+	optional    bool
+	variadic    bool // whether it takes every argument that's left
+	// Returns what the argument can be, for shell completion
+	complete func() []string
+}
+
+// A flag of a command, e.g. --format. What it holds follows from value, its
+// default, which is either a bool, a string, or an int.
+type commandFlag struct {
+	name        string
+	value       interface{}
+	description string
+	choices     []string // the values that a string flag accepts, if limited
+	// Returns what the flag can be set to, for shell completion
+	complete func() []string
+}
+
+// A command as it was called, with its flags and arguments parsed.
+type commandCall struct {
+	name  string
+	flags *flag.FlagSet
+	args  []string
+}
+
+// The status that webes exits with: 0 when the command succeeded, and
+// otherwise one of these.
+const (
+	exitFailure = 1 // the command failed, e.g. a page couldn't be built
+	exitUsage   = 2 // the command was given the wrong flags or arguments
+	exitProject = 3 // there's no project, or its webes.json is invalid
+)
+
+// An error in the way that a command was called, such as an unknown flag.
+type usageError struct {
+	command string // "" if the command itself is missing or unknown
+	msg     string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// An error finding or opening the project that a command works on.
+type projectError struct {
+	err error
+}
+
+func (e *projectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *projectError) Unwrap() error {
+	return e.err
+}
+
+// Returns the status that webes exits with after err. Errors with the
+// usage-error rule (e.g. E305, for `--page` naming no page) are usage errors
+// too, even though they're reported as diagnostics.
+func exitCode(err error) int {
+	var usageErr *usageError
+	var projectErr *projectError
+	var webesErr *webesError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.As(err, &webesErr) && webesErr.Rule == "usage-error":
+		return exitUsage
+	case errors.As(err, &projectErr):
+		return exitProject
+	}
+	return exitFailure
+}
+
+// Runs the command that args (the command line, without the program's name)
+// calls, returning the status that webes should exit with. Errors are printed
+// to stderr, so that they don't mix with the output of e.g. `webes graph`.
+func runCommand(args []string) int {
+	err := callCommand(args)
+	if err == nil {
+		return 0
+	}
+	// Errors with a code point at where the problem is, and at how to fix it
+	var webesErr *webesError
+	if errors.As(err, &webesErr) {
+		lib.FprintDiagnostic(os.Stderr, webesErr.Diagnostic)
+		lib.FmtFprint(os.Stderr, "Run `webes explain "+webesErr.Code+
+			"` for how to fix this", "info")
+		return exitCode(err)
+	}
+	lib.FmtFprint(os.Stderr, err.Error(), "error")
+	// A missing or unknown command is followed by the ones there are
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		if usageErr.command == "" {
+			printCommandList()
+		} else {
+			lib.FmtFprint(os.Stderr, "Run `webes help "+usageErr.command+
+				"` for usage", "info")
+		}
+	}
+	return exitCode(err)
+}
+
+func callCommand(args []string) error {
+	// Determine if user forgot to specify a webes-command
+	// (i.e. user entered `webes` as opposed to `webes init`)
+	if len(args) == 0 {
+		return &usageError{msg: "Command not specified"}
+	}
+	var name string = args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		return &usageError{msg: "Command \"" + name + "\" not found"}
+	}
+
+	call, err := parseCall(name, cmd, args[1:])
+	if err == flag.ErrHelp {
+		printCommandHelp(name, cmd)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// It's useful to provide confirmation to the user, even if they don't
+	// need it 99% of the time.
+	if !cmd.hidden {
+		lib.FmtFprint(os.Stderr, "Running `"+name+"`...", "info")
+	}
+
+	// Commands that work on a project find its root, so that they can be run
+	// from anywhere within it. The others (e.g. init) work on the working
+	// directory, along with any settings that it has.
+	if cmd.standalone {
+		return cmd.function(call)
+	}
+	if cmd.needsProject {
+		project, err = findProject(".")
+	} else {
+		project, err = openProject(".")
+	}
+	if err != nil {
+		return &projectError{err}
+	}
+	if cmd.needsProject {
+		fmt.Fprintln(os.Stderr, "Project:", project.root)
+	}
+	return cmd.function(call)
+}
+
+// Parses the flags and arguments that cmd was called with.
+func parseCall(name string, cmd Command, args []string) (*commandCall,
+	error) {
+	var call = &commandCall{name: name,
+		flags: flag.NewFlagSet(name, flag.ContinueOnError)}
+	call.flags.SetOutput(io.Discard)
+	for _, f := range cmd.flags {
+		switch value := f.value.(type) {
+		case bool:
+			call.flags.Bool(f.name, value, f.description)
+		case string:
+			call.flags.String(f.name, value, f.description)
+		case int:
+			call.flags.Int(f.name, value, f.description)
+		}
+	}
+	if err := call.flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		var msg string = err.Error()
+		if strings.HasPrefix(msg, "flag provided but not defined: -") {
+			msg = "Unknown flag -" + strings.TrimPrefix(msg,
+				"flag provided but not defined: ")
+		}
+		return nil, &usageError{name, msg}
+	}
+
+	for _, f := range cmd.flags {
+		if f.choices == nil {
+			continue
+		}
+		var value string = call.stringFlag(f.name)
+		if !contains(f.choices, value) {
+			return nil, &usageError{name, "Unknown " + f.name + " \"" + value +
+				"\", expected one of: " + strings.Join(f.choices, ", ")}
+		}
+	}
+
+	call.args = call.flags.Args()
+	var required int = 0
+	for _, arg := range cmd.args {
+		if !arg.optional {
+			required++
+		}
+	}
+	if len(call.args) < required {
+		return nil, &usageError{name, "Missing " +
+			argName(cmd.args[len(call.args)]) + ", usage: " +
+			commandUsage(name, cmd)}
+	}
+	var variadic bool = len(cmd.args) > 0 && cmd.args[len(cmd.args)-1].variadic
+	if len(call.args) > len(cmd.args) && !variadic {
+		return nil, &usageError{name, "Unexpected argument \"" +
+			call.args[len(cmd.args)] + "\", usage: " +
+			commandUsage(name, cmd)}
+	}
+	return call, nil
+}
+
+func (call *commandCall) boolFlag(name string) bool {
+	return call.flags.Lookup(name).Value.(flag.Getter).Get().(bool)
+}
+
+func (call *commandCall) stringFlag(name string) string {
+	return call.flags.Lookup(name).Value.(flag.Getter).Get().(string)
+}
+
+func (call *commandCall) intFlag(name string) int {
+	return call.flags.Lookup(name).Value.(flag.Getter).Get().(int)
+}
+
+// Returns the i-th positional argument, or "" if it wasn't given.
+func (call *commandCall) arg(i int) string {
+	if i < len(call.args) {
+		return call.args[i]
+	}
+	return ""
+}
+
+// Returns the names of every command that isn't hidden, sorted.
+func commandNames() []string {
+	var names []string
+	for name, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns arg as it's shown in usage, e.g. "<component>".
+func argName(arg commandArg) string {
+	var name string = arg.name
+	if arg.variadic {
+		name += "..."
+	}
+	if arg.optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// Returns how flag f is written, e.g. "--format=text|json|sarif".
+func flagName(f commandFlag) string {
+	switch value := f.value.(type) {
+	case string:
+		if f.choices != nil {
+			return "--" + f.name + "=" + strings.Join(f.choices, "|")
+		}
+		if value == "" {
+			return "--" + f.name + "=<" + f.name + ">"
+		}
+		return "--" + f.name + "=" + value
+	case int:
+		return "--" + f.name + "=" + strconv.Itoa(value)
+	}
+	return "--" + f.name
+}
+
+// Returns the usage line of a command, e.g.
+// `webes validate [--format=text|json|sarif]`.
+func commandUsage(name string, cmd Command) string {
+	var usage string = "webes " + name
+	for _, f := range cmd.flags {
+		usage += " [" + flagName(f) + "]"
+	}
+	for _, arg := range cmd.args {
+		usage += " " + argName(arg)
+	}
+	return usage
+}
+
+// Prints the usage of a command, along with its flags, arguments, and
+// examples.
+func printCommandHelp(name string, cmd Command) {
+	fmt.Println("Usage: " + commandUsage(name, cmd))
+	fmt.Println()
+	fmt.Println(cmd.description)
+
+	var rows [][2]string
+	for _, arg := range cmd.args {
+		rows = append(rows, [2]string{argName(arg), arg.description})
+	}
+	printHelpSection("Arguments", rows)
+	rows = nil
+	for _, f := range cmd.flags {
+		var description string = f.description
+		if f.value != false && f.value != "" {
+			description += " (default " + fmt.Sprint(f.value) + ")"
+		}
+		rows = append(rows, [2]string{"--" + f.name, description})
+	}
+	printHelpSection("Flags", rows)
+
+	if len(cmd.examples) > 0 {
+		fmt.Println()
+		fmt.Println("Examples:")
+		for _, example := range cmd.examples {
+			fmt.Println("  " + example)
+		}
+	}
+}
+
+// Prints rows of names and descriptions under a heading, with the
+// descriptions lined up.
+func printHelpSection(heading string, rows [][2]string) {
+	if len(rows) == 0 {
+		return
+	}
+	var width int = 0
+	for _, row := range rows {
+		if len(row[0]) > width {
+			width = len(row[0])
+		}
+	}
+	fmt.Println()
+	fmt.Println(heading + ":")
+	for _, row := range rows {
+		fmt.Printf("  %-*s  %s\n", width, row[0], row[1])
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	var tests = []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("boom"), exitFailure},
+		{&usageError{command: "build", msg: "unknown flag"}, exitUsage},
+		{newError("E305", "page \"typo\" not found in dev/pages"), exitUsage},
+		{fmt.Errorf("building: %w",
+			newError("E305", "page \"typo\" not found")), exitUsage},
+		{&projectError{err: errors.New("no webes.json")}, exitProject},
+		{newError("E301", "can't read page.html"), exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
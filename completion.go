@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"     // Used for printing scripts and candidates
+	"os"      // Used for checking for dev/pages
+	"sort"    // Used for ordering candidates
+	"strings" // Used for string manipulation
+)
+
+// Shell completion works through `webes __complete`, which the scripts that
+// `webes completion` prints call with the words typed so far. It prints what
+// the last of them (the one being typed, which may be empty) can be completed
+// to, one per line, based on the commands map. That way the scripts never
+// have to change when a command or flag is added.
+
+// Completion scripts, by shell. Each passes the words after `webes` to
+// `webes __complete`, with an empty word if the cursor is after a space.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for webes
+_webes() {
+	local line="${COMP_LINE:0:COMP_POINT}" IFS=$'\n'
+	local -a words
+	IFS=$' \t' read -ra words <<< "$line"
+	[[ "$line" =~ [[:space:]]$ ]] && words+=("")
+	COMPREPLY=($(webes __complete -- "${words[@]:1}" 2>/dev/null))
+	# Bash completes the part after the "=" of --flag=value on its own
+	if [[ "${words[-1]}" == *=* ]]; then
+		COMPREPLY=("${COMPREPLY[@]#*=}")
+	fi
+	if [[ ${#COMPREPLY[@]} -eq 1 && "${COMPREPLY[0]}" == *= ]]; then
+		compopt -o nospace
+	fi
+}
+complete -F _webes webes
+`,
+	"zsh": `#compdef webes
+# zsh completion for webes
+_webes() {
+	local -a candidates
+	local out
+	out=$(webes __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)
+	candidates=(${(f)out})
+	compadd -S '' -- ${(M)candidates:#*=}
+	compadd -- ${candidates:#*=}
+}
+# Loaded from $fpath, the file is the completion function itself
+if [[ "${funcstack[1]}" == "_webes" ]]; then
+	_webes "$@"
+else
+	compdef _webes webes
+fi
+`,
+	"fish": `# fish completion for webes
+function __webes_complete
+	set -l words (commandline -opc) (commandline -ct)
+	webes __complete -- $words[2..-1] 2>/dev/null
+end
+complete -c webes -f -a '(__webes_complete)'
+`,
+}
+
+// Returns the shells that `webes completion` has scripts for.
+func completionShells() []string {
+	var shells []string
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// Prints the completion script for a shell, which completes commands, flags,
+// and (within a project) component and page names.
+// Callable via `webes completion <bash|zsh|fish>`
+func webes_completion(call *commandCall) error {
+	script, ok := completionScripts[call.arg(0)]
+	if !ok {
+		return &usageError{call.name, "Unknown shell \"" + call.arg(0) +
+			"\", expected one of: " + strings.Join(completionShells(), ", ")}
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// Prints what the last of the given words can be completed to. Called by the
+// completion scripts, not by users.
+// Callable via `webes __complete -- [words...]`
+func webes_complete(call *commandCall) error {
+	var words []string = call.args
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	for _, candidate := range completeWords(words[:len(words)-1],
+		words[len(words)-1]) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// Returns what current can be completed to, after the words before it.
+func completeWords(before []string, current string) []string {
+	if len(before) == 0 {
+		return withPrefix(commandNames(), current)
+	}
+	cmd, ok := commands[before[0]]
+	if !ok {
+		return nil
+	}
+
+	// The value of a flag, given after it or after its "="
+	if strings.HasPrefix(current, "-") && strings.Contains(current, "=") {
+		var i int = strings.Index(current, "=")
+		f, ok := findFlag(cmd, current[:i])
+		if !ok {
+			return nil
+		}
+		var values []string
+		for _, value := range withPrefix(flagValues(f), current[i+1:]) {
+			values = append(values, current[:i+1]+value)
+		}
+		return values
+	}
+	if len(before) > 1 {
+		var last string = before[len(before)-1]
+		if f, ok := findFlag(cmd, last); ok && !strings.Contains(last, "=") {
+			if _, isBool := f.value.(bool); !isBool {
+				return withPrefix(flagValues(f), current)
+			}
+		}
+	}
+
+	// Flags go before the arguments, so they're only offered until the
+	// first argument
+	var args []string
+	for i := 1; i < len(before); i++ {
+		var word string = before[i]
+		if !strings.HasPrefix(word, "-") {
+			args = append(args, word)
+			continue
+		}
+		f, ok := findFlag(cmd, word)
+		if _, isBool := f.value.(bool); ok && !isBool &&
+			!strings.Contains(word, "=") {
+			i++ // the flag's value
+		}
+	}
+	if strings.HasPrefix(current, "-") {
+		if len(args) > 0 {
+			return nil
+		}
+		var names []string
+		for _, f := range cmd.flags {
+			if _, isBool := f.value.(bool); isBool {
+				names = append(names, "--"+f.name)
+			} else {
+				names = append(names, "--"+f.name+"=")
+			}
+		}
+		return withPrefix(names, current)
+	}
+
+	var arg commandArg
+	switch {
+	case len(args) < len(cmd.args):
+		arg = cmd.args[len(args)]
+	case len(cmd.args) > 0 && cmd.args[len(cmd.args)-1].variadic:
+		arg = cmd.args[len(cmd.args)-1]
+	default:
+		return nil
+	}
+	if arg.complete == nil {
+		return nil
+	}
+	return withPrefix(arg.complete(), current)
+}
+
+// Returns the flag of cmd that word (e.g. "--format" or "-format=json")
+// sets.
+func findFlag(cmd Command, word string) (commandFlag, bool) {
+	var name string = strings.TrimLeft(strings.SplitN(word, "=", 2)[0], "-")
+	for _, f := range cmd.flags {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return commandFlag{}, false
+}
+
+// Returns what flag f can be set to, if that's known.
+func flagValues(f commandFlag) []string {
+	if f.complete != nil {
+		return f.complete()
+	}
+	return f.choices
+}
+
+// Returns the candidates that start with prefix.
+func withPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// Opens the project that the working directory is within for completion,
+// returning false (so that nothing is offered) if there's none.
+func openCompletionProject() bool {
+	p, err := findProject(".")
+	if err != nil {
+		return false
+	}
+	project = p
+	return true
+}
+
+// Returns the names of the project's components, e.g. "_helloWorld" and
+// "forms/_input". Layouts are left out.
+func completeComponents() []string {
+	if !openCompletionProject() {
+		return nil
+	}
+	components, err := loadComponents()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, c := range components {
+		if !strings.HasPrefix(c.name, layoutPrefix) {
+			names = append(names, c.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the names of the project's pages, e.g. "index.html" and
+// "blog/post.md".
+func completePages() []string {
+	if !openCompletionProject() {
+		return nil
+	}
+	if _, err := os.Stat(devPath("pages")); err != nil {
+		return nil
+	}
+	pages, err := findPages()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, p := range pages {
+		names = append(names, pageName(p))
+	}
+	return names
+}
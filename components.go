@@ -0,0 +1,460 @@
+package main
+
+import (
+	"io/fs"         // Used for walking dev/components
+	"os"            // Used for reading component files
+	"path/filepath" // Used for building file paths
+	"strings"       // Used for string manipulation
+	"unicode"       // Used for classifying characters in tags
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// The 3 sections of a .webes component file, along with where it came from.
+type component struct {
+	// The path within dev/components without the extension, e.g.
+	// "_helloWorld" or "forms/_input". Layouts are named after their path
+	// within dev/layouts instead, with layoutPrefix in front: "layout:default"
+	name     string
+	path     string
+	src      string // the whole file, as read from path
+	template string
+	style    string
+	script   string
+	props    string // the <props> block, see prop
+	// The props declared by the <props> block
+	propDecls []prop
+	// The offset within src that each section's content starts at, keyed by
+	// section name
+	offsets map[string]int
+	lines   *lib.LineIndex
+}
+
+// Layouts are components too, they're just kept in dev/layouts rather than
+// dev/components, and used through <webes-layout name="..."> (see
+// parsePage()). Their names start with this prefix, which keeps them from
+// being used as regular components.
+const layoutPrefix = "layout:"
+
+// Reads every *.webes file in dev/components and dev/layouts (and their
+// subdirectories) into a map keyed by the component's componentKey().
+func loadComponents() (map[string]*component, error) {
+	var components = make(map[string]*component)
+
+	paths, err := findComponentFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		c, err := readComponent(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := components[componentKey(c.name)]; ok {
+			return nil, collisionError(other, c)
+		}
+		components[componentKey(c.name)] = c
+	}
+	return components, nil
+}
+
+// Returns the path of every *.webes file within dev/components, dev/layouts,
+// and their subdirectories, in lexical order. Projects don't need to have any
+// layouts, so a missing dev/layouts is fine.
+func findComponentFiles() ([]string, error) {
+	var paths []string
+	for _, dir := range []string{"components", "layouts"} {
+		err := filepath.WalkDir(devPath(dir),
+			func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && filepath.Ext(path) == ".webes" {
+					paths = append(paths, path)
+				}
+				return nil
+			})
+		if err != nil && !(dir == "layouts" && os.IsNotExist(err)) {
+			return paths, readError(devPath(dir), err)
+		}
+	}
+	return paths, nil
+}
+
+// Returns the error for components a and b ending up with the same
+// componentKey(), which would make it impossible to tell them apart.
+func collisionError(a *component, b *component) error {
+	if strings.HasPrefix(a.name, layoutPrefix) {
+		return newError("E203", "layouts %s and %s have conflicting names",
+			project.rel(a.path), project.rel(b.path))
+	}
+	return newError("E203",
+		"components %s and %s have conflicting names, both are used as <%s>",
+		project.rel(a.path), project.rel(b.path), componentTag(a.name))
+}
+
+// Reads a single .webes file within dev/components or dev/layouts and splits
+// it into its sections. Anything after the optional `#end` marker (see
+// endMarker) is ignored.
+func readComponent(path string) (*component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, readError(path, err)
+	}
+	name, err := filepath.Rel(devPath("components"), path)
+	if err != nil {
+		return nil, err
+	}
+	if layout, err := filepath.Rel(devPath("layouts"), path); err == nil &&
+		!strings.HasPrefix(layout, "..") {
+		name = layoutPrefix + layout
+	}
+	var fileStr string = string(data)
+	if end := endMarker(fileStr); end != -1 {
+		fileStr = fileStr[:end]
+	}
+
+	c := &component{
+		name:    strings.TrimSuffix(filepath.ToSlash(name), ".webes"),
+		path:    path,
+		src:     string(data),
+		offsets: make(map[string]int),
+		lines:   lib.NewLineIndex(string(data)),
+	}
+	// The template is cut out first so that any <style> or <script> tags
+	// inside of it aren't mistaken for the component's own sections.
+	c.template, c.offsets["template"], fileStr = cutSection(fileStr,
+		"template")
+	c.props, c.offsets["props"], fileStr = cutSection(fileStr, "props")
+	c.style, c.offsets["style"], fileStr = cutSection(fileStr, "style")
+	c.script, c.offsets["script"], _ = cutSection(fileStr, "script")
+	if err := parseProps(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// The sections of a .webes file, in the order that they're cut out.
+var componentSections = []string{"template", "props", "style", "script"}
+
+// Returns the offset of the `#end` marker in fileStr, or -1 if there's none.
+// The marker has to be on a line of its own, outside of any section, so that
+// e.g. `href="#endnotes"` or a script that mentions it doesn't end the file.
+func endMarker(fileStr string) int {
+	var open string // the section that the line is within, if any
+	var depth int = 0
+	var offset int = 0
+	for _, line := range strings.SplitAfter(fileStr, "\n") {
+		if open == "" && strings.TrimSpace(line) == "#end" {
+			return offset
+		}
+		offset += len(line)
+		if open == "" {
+			for _, section := range componentSections {
+				if strings.Contains(line, "<"+section+">") {
+					open = section
+					break
+				}
+			}
+		}
+		if open != "" {
+			// Sections may hold tags of their own kind, e.g. a <template>
+			// element within the template
+			depth += strings.Count(line, "<"+open+">") -
+				strings.Count(line, "</"+open+">")
+			if depth <= 0 {
+				open, depth = "", 0
+			}
+		}
+	}
+	return -1
+}
+
+// Returns everything between <section> and </section> in fileStr along with
+// the offset it starts at, as well as fileStr with that whole section blanked
+// out (so that offsets within it stay the same). If the section doesn't
+// exist, an empty string and the unchanged fileStr are returned.
+func cutSection(fileStr string, section string) (string, int, string) {
+	var openTag string = "<" + section + ">"
+	var closeTag string = "</" + section + ">"
+
+	startIdx := strings.Index(fileStr, openTag)
+	endIdx := strings.LastIndex(fileStr, closeTag)
+	if startIdx == -1 || endIdx < startIdx {
+		return "", 0, fileStr
+	}
+	return fileStr[startIdx+len(openTag) : endIdx], startIdx + len(openTag),
+		fileStr[:startIdx] +
+			strings.Repeat(" ", endIdx+len(closeTag)-startIdx) +
+			fileStr[endIdx+len(closeTag):]
+}
+
+// Returns the position within the component's file of the offset within one
+// of its sections.
+func (c *component) pos(section string, offset int) lib.Pos {
+	return c.lines.Pos(c.offsets[section] + offset)
+}
+
+// Returns the key that components are looked up by, so that a component
+// named _helloWorld can be referred to as `_helloWorld`, `HelloWorld`, or
+// `hello-world`. Components within subdirectories are namespaced by them, so
+// forms/_input is `forms/_input`, `FormsInput`, or `forms-input`.
+func componentKey(name string) string {
+	var key strings.Builder
+	for _, segment := range strings.Split(strings.TrimSuffix(name, ".webes"),
+		"/") {
+		segment = strings.TrimPrefix(segment, "_")
+		key.WriteString(strings.ToLower(strings.Replace(segment, "-", "", -1)))
+	}
+	return key.String()
+}
+
+// Returns the hyphenated tag that the component called name is used as, e.g.
+// "hello-world" for _helloWorld and "forms-input" for forms/_input.
+func componentTag(name string) string {
+	var tag []rune
+	for _, segment := range strings.Split(name, "/") {
+		if len(tag) > 0 {
+			tag = append(tag, '-')
+		}
+		for i, r := range strings.TrimPrefix(segment, "_") {
+			if unicode.IsUpper(r) {
+				if i > 0 && tag[len(tag)-1] != '-' {
+					tag = append(tag, '-')
+				}
+				r = unicode.ToLower(r)
+			}
+			tag = append(tag, r)
+		}
+	}
+	return string(tag)
+}
+
+// Tags that start with an uppercase letter (<HelloWorld />) or contain a
+// hyphen (<hello-world />) may refer to components. Plain lowercase tags are
+// always regular HTML elements.
+func isComponentTag(name string) bool {
+	return name != "" && (unicode.IsUpper(rune(name[0])) ||
+		strings.Contains(name, "-"))
+}
+
+// Returns the component (or layout, for <webes-layout>) that the element el
+// refers to. ok is false for elements that aren't components, while component
+// tags that don't name an existing component result in an error.
+func lookupComponent(el *lib.HTMLNode,
+	components map[string]*component) (c *component, ok bool, err error) {
+	if el.Type != lib.HTMLElementNode || !isComponentTag(el.Name) {
+		return nil, false, nil
+	}
+
+	var name string = el.Name
+	if el.Is("webes-layout") {
+		attr, ok := el.Attr("name")
+		if !ok {
+			return nil, false, newError("E201", "<webes-layout> on line %d is "+
+				"missing its name attribute", el.Pos.Line)
+		}
+		c, ok = components[componentKey(layoutPrefix+attr.Value)]
+		if !ok {
+			return nil, false, newError("E201", "layout \"%s\" (line %d) not "+
+				"found in dev/layouts", attr.Value, el.Pos.Line)
+		}
+		return c, true, nil
+	}
+	if el.Is("webes-include") {
+		src, ok := el.Attr("src")
+		if !ok {
+			return nil, false, newError("E201", "<webes-include> on line %d is "+
+				"missing its src attribute", el.Pos.Line)
+		}
+		name = src.Value
+	}
+	c, ok = components[componentKey(name)]
+	if !ok {
+		// Hyphenated tags are also used by custom elements, so only complain
+		// about the ones that can't be anything but a component.
+		if el.Is("webes-include") || unicode.IsUpper(rune(el.Name[0])) {
+			return nil, false, newError("E201", "component \"%s\" (line "+
+				"%d) not found in dev/components", name, el.Pos.Line)
+		}
+		return nil, false, nil
+	}
+	return c, true, nil
+}
+
+// Returns where the element n names the component (or layout) that it
+// refers to, as an offset and a length: the value of the name attribute of a
+// <webes-layout> or the src attribute of a <webes-include>, or else the tag
+// name itself.
+func componentNameSpan(n *lib.HTMLNode) (int, int) {
+	var attrName string = ""
+	if n.Is("webes-layout") {
+		attrName = "name"
+	} else if n.Is("webes-include") {
+		attrName = "src"
+	}
+	if attr, ok := n.Attr(attrName); ok && attrName != "" {
+		return attr.ValuePos.Offset, len(attr.Raw)
+	}
+	// Right after the "<"
+	return n.Pos.Offset + 1, len(n.Name)
+}
+
+// Replaces every component tag within doc with the template of the component
+// it names, expanding any components used by that template as well.
+// Components can be used as `<HelloWorld />`, `<hello-world></hello-world>`,
+// or `<webes-include src="_helloWorld" />`. Returns every component that was
+// included, in order of first use.
+func expandIncludes(doc *lib.HTMLNode,
+	components map[string]*component) ([]*component, error) {
+	var used []*component
+	err := expandComponents(doc, components, nil, &used)
+	return used, err
+}
+
+// Does the work of expandIncludes(). stack holds the names of the components
+// currently being expanded, and is used to catch components that (directly or
+// indirectly) include themselves.
+func expandComponents(root *lib.HTMLNode, components map[string]*component,
+	stack []string, used *[]*component) error {
+	var err error
+
+	root.Walk(func(n *lib.HTMLNode) bool {
+		if err != nil {
+			return false
+		}
+		c, ok, lookupErr := lookupComponent(n, components)
+		if lookupErr != nil {
+			err = lookupErr
+			return false
+		}
+		if !ok {
+			return true
+		}
+		if contains(stack, c.name) {
+			err = newError("E202", "component cycle: %s",
+				strings.Join(append(stack, c.name), " -> "))
+			return false
+		}
+		if !containsComponent(*used, c) {
+			*used = append(*used, c)
+		}
+
+		values, problems := propValues(c, n)
+		if len(problems) > 0 {
+			err = newError("E204", "%s (line %d)", problems[0].msg,
+				problems[0].pos.Line)
+			return false
+		}
+
+		// Whatever sits between <HelloWorld> and </HelloWorld> fills the
+		// component's slots. It belongs to the page or component using c, so
+		// it's expanded here rather than as part of c.
+		content, names := slotContent(n)
+		for _, name := range names {
+			var holder = &lib.HTMLNode{Type: lib.HTMLDocumentNode}
+			for _, child := range content[name] {
+				holder.AppendChild(child)
+			}
+			if err = expandComponents(holder, components, stack,
+				used); err != nil {
+				return false
+			}
+			content[name] = holder.Children
+		}
+
+		nodes, instErr := instantiate(c, values, content, components, stack,
+			used)
+		if instErr != nil {
+			err = instErr
+			return false
+		}
+		n.ReplaceWith(nodes...)
+		return false
+	})
+	return err
+}
+
+// Returns a fresh copy of c's template, with its props set to values, scoped
+// to c, its slots filled with content, and with any components that it uses
+// expanded.
+func instantiate(c *component, values map[string]string,
+	content map[string][]*lib.HTMLNode, components map[string]*component,
+	stack []string, used *[]*component) ([]*lib.HTMLNode, error) {
+	var template *lib.HTMLNode = lib.ParseHTML(strings.TrimSpace(c.template))
+	substituteProps(template, values)
+
+	// The component's own elements are scoped before any components that it
+	// uses are expanded.
+	if strings.TrimSpace(c.style) != "" {
+		scopeTemplate(template, scopeAttr(c), components)
+	}
+	if strings.TrimSpace(c.script) != "" {
+		scopeHandlers(template, c, components)
+	}
+
+	// The content was already expanded by the caller, so nothing within it
+	// is expanded twice
+	fillSlots(template, content)
+
+	var nested = append(append([]string{}, stack...), c.name)
+	err := expandComponents(template, components, nested, used)
+	return template.Children, err
+}
+
+// A problem with the way that a component is used, found at pos within the
+// page or template that uses it.
+type usageProblem struct {
+	pos      lib.Pos
+	length   int // how many characters the problem spans
+	msg      string
+	rule     string
+	severity string
+}
+
+// Returns a diagnostic for every problem with the way that the components
+// used within doc are used: the components that don't exist, the props
+// they're given, and the slots they're filled with. doc was parsed from src,
+// the file at path, and pos maps offsets within doc to positions within src.
+func checkUsage(doc *lib.HTMLNode, components map[string]*component,
+	path string, src string, pos func(int) lib.Pos) []lib.Diagnostic {
+	var diags []lib.Diagnostic
+	doc.Walk(func(n *lib.HTMLNode) bool {
+		c, ok, err := lookupComponent(n, components)
+		if err != nil {
+			var d lib.Diagnostic = errorDiagnostic(err, "E201")
+			offset, length := componentNameSpan(n)
+			d.File, d.Pos, d.Len = project.rel(path), pos(offset), length
+			d.Source = src
+			diags = append(diags, d)
+			return true
+		}
+		if !ok {
+			return true
+		}
+		_, problems := propValues(c, n)
+		for _, problem := range append(problems, slotProblems(c, n)...) {
+			diags = append(diags, lib.Diagnostic{
+				Rule:     problem.rule,
+				Code:     ruleCode(problem.rule),
+				Severity: problem.severity,
+				Message:  problem.msg,
+				File:     project.rel(path),
+				Pos:      pos(problem.pos.Offset),
+				Len:      problem.length,
+				Source:   src,
+			})
+		}
+		return true
+	})
+	return diags
+}
+
+func containsComponent(arr []*component, c *component) bool {
+	for _, e := range arr {
+		if e == c {
+			return true
+		}
+	}
+	return false
+}
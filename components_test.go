@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEndMarker(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want int
+	}{
+		{"<template></template>\n#end\nnotes", 22},
+		{"<template></template>\n  #end  \n", 22},
+		{"<template></template>\n", -1},
+		{"<template><a href=\"#endnotes\"></a></template>\n", -1},
+		{"<template></template>\nsee #end below\n", -1},
+		{"<template>\n#end\n</template>\n#end\n", 28},
+		{"<script>\nconst s = `\n#end\n`\n</script>\n", -1},
+		{"<template>\n<template>\n</template>\n#end\n</template>\n", -1},
+		{"<style></style>\n<script>\n</script>\n#end\n", 35},
+	}
+	for _, test := range tests {
+		if got := endMarker(test.src); got != test.want {
+			t.Errorf("endMarker(%q) = %d, want %d", test.src, got, test.want)
+		}
+	}
+}
+
+func TestCheckUsageMissingComponents(t *testing.T) {
+	var dir string = t.TempDir()
+	project = &Project{root: dir, dev: filepath.Join(dir, "dev"),
+		dist: filepath.Join(dir, "dist"), config: defaultConfig()}
+	var tests = []struct {
+		name      string
+		src       string
+		line, col int
+		length    int
+	}{
+		{"tag", "<p>\n  <HeroBanner />\n</p>", 2, 4, 10},
+		{"include", "<webes-include src=\"_hero\" />", 1, 21, 5},
+		{"layout element", "<webes-layout name=\"nope\"></webes-layout>",
+			1, 21, 4},
+		// A layout named by the front matter has no tag to point at
+		{"front matter", "---\ntitle: Hi\nlayout: nope\n---\n<p>a</p>", 3,
+			9, 4},
+	}
+	for _, test := range tests {
+		var path string = filepath.Join(dir, "page.html")
+		if err := os.WriteFile(path, []byte(test.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		src, err := readPage(page{src: path})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		diags := checkUsage(parsePage(src, nil), nil, path, src.file,
+			src.lines.Pos)
+		if len(diags) != 1 {
+			t.Errorf("%s: got %d diagnostics, want 1", test.name, len(diags))
+			continue
+		}
+		var d = diags[0]
+		if d.Code != "E201" || d.Pos.Line != test.line ||
+			d.Pos.Col != test.col || d.Len != test.length {
+			t.Errorf("%s: got %s at %d:%d (length %d), want E201 at %d:%d "+
+				"(length %d)", test.name, d.Code, d.Pos.Line, d.Pos.Col,
+				d.Len, test.line, test.col, test.length)
+		}
+	}
+}
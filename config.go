@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json" // Used for writing the config
+	"errors"        // Used for unwrapping decoding errors
+	"fmt"           // Used for building errors
+	"net/url"       // Used for checking the site's URL
+	"os"            // Used for reading the config
+	"path/filepath" // Used for building file paths
+	"sort"          // Used for listing rules in a stable order
+	"strings"       // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// The file holding a project's settings, next to dev/ and dist/. `webes init`
+// creates it, and every command reads it (a project without one gets the
+// defaults).
+const configFile = "webes.json"
+
+// The settings of a project, as read from webes.json.
+type projectConfig struct {
+	Site     siteConfig     `json:"site"`
+	Dirs     dirsConfig     `json:"dirs"`
+	Build    buildConfig    `json:"build"`
+	Validate validateConfig `json:"validate"`
+}
+
+// What every page's metadata is filled in with, unless its front matter says
+// otherwise.
+type siteConfig struct {
+	Name        string `json:"name"`        // becomes og:site_name
+	URL         string `json:"url"`         // where dist/ ends up being served
+	Author      string `json:"author"`      // becomes <meta name="author">
+	Twitter     string `json:"twitter"`     // the site's handle, e.g. "@webes"
+	Description string `json:"description"` // for pages without their own
+}
+
+// Where the project's directories are, relative to webes.json.
+type dirsConfig struct {
+	Dev  string `json:"dev"`
+	Dist string `json:"dist"`
+}
+
+type buildConfig struct {
+	// Whether unused selectors and functions are left out of dist/
+	Prune bool `json:"prune"`
+	// Classes, ids and functions that are kept in every component, as if
+	// each of them had them in a webes-safelist comment
+	Safelist []string `json:"safelist"`
+	// Files within the asset directories (relative to dev/, e.g.
+	// "imgs/gallery-*") that are copied into dist/ even though no page refers
+	// to them, such as those that only a script loads
+	Assets []string `json:"assets"`
+}
+
+type validateConfig struct {
+	// Rule id -> "error", "warning" or "off"
+	Rules map[string]string `json:"rules"`
+}
+
+// The rules that webes.json can change the severity of. The others always
+// stop the build, so they can't be turned off.
+var configurableRules = []string{"unused-class", "unused-id",
+	"unused-function", "unused-prop", "unused-asset", "unknown-slot"}
+
+// Returns the settings of a project without a webes.json.
+func defaultConfig() projectConfig {
+	return projectConfig{
+		Dirs: dirsConfig{Dev: "dev", Dist: "dist"},
+		Build: buildConfig{Prune: true, Safelist: []string{},
+			Assets: []string{}},
+		Validate: validateConfig{Rules: map[string]string{}},
+	}
+}
+
+// Loads the webes.json of the project whose root is root. Unknown keys,
+// values of the wrong type and invalid settings are returned as errors
+// pointing at the problem.
+func loadConfig(root string) (projectConfig, error) {
+	var cfg projectConfig = defaultConfig()
+	data, err := os.ReadFile(filepath.Join(root, configFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		e := newError("E304", "%s couldn't be read: %s", configFile,
+			errorReason(err))
+		e.err = err
+		return cfg, e
+	}
+
+	if err := lib.DecodeJSONStrict(string(data), &cfg); err != nil {
+		e := newError("E304", "%s", err)
+		e.File, e.err = configFile, err
+		var jsonErr *lib.JSONError
+		if errors.As(err, &jsonErr) {
+			e.Message, e.Pos, e.Source = jsonErr.Msg, jsonErr.Pos, string(data)
+		}
+		return cfg, e
+	}
+	if err := cfg.check(); err != nil {
+		e := newError("E304", "%s", err)
+		e.File, e.err = configFile, err
+		return cfg, e
+	}
+	return cfg, nil
+}
+
+// Checks the values of the settings, tidying them up where there's no doubt
+// about what's meant (e.g. a trailing slash on the site's URL).
+func (cfg *projectConfig) check() error {
+	if cfg.Site.URL != "" {
+		u, err := url.Parse(cfg.Site.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+			return fmt.Errorf("\"site.url\" should be an absolute URL such "+
+				"as https://example.com, not \"%s\"", cfg.Site.URL)
+		}
+		cfg.Site.URL = strings.TrimRight(cfg.Site.URL, "/")
+	}
+	if cfg.Site.Twitter != "" && !strings.HasPrefix(cfg.Site.Twitter, "@") {
+		cfg.Site.Twitter = "@" + cfg.Site.Twitter
+	}
+
+	if strings.TrimSpace(cfg.Dirs.Dev) == "" ||
+		strings.TrimSpace(cfg.Dirs.Dist) == "" {
+		return errors.New("\"dirs.dev\" and \"dirs.dist\" can't be empty")
+	}
+	cfg.Dirs.Dev = filepath.Clean(cfg.Dirs.Dev)
+	cfg.Dirs.Dist = filepath.Clean(cfg.Dirs.Dist)
+	// `webes wipe` and every full build remove these directories, so they
+	// have to be well within the project, and apart from each other
+	for _, dir := range []struct{ key, path string }{
+		{"dirs.dev", cfg.Dirs.Dev}, {"dirs.dist", cfg.Dirs.Dist}} {
+		if filepath.IsAbs(dir.path) || dir.path == "." ||
+			dir.path == ".." ||
+			strings.HasPrefix(dir.path, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("\"%s\" should be a directory within the "+
+				"project, relative to %s, not \"%s\"", dir.key, configFile,
+				filepath.ToSlash(dir.path))
+		}
+	}
+	if cfg.Dirs.Dev == cfg.Dirs.Dist {
+		return fmt.Errorf("\"dirs.dev\" and \"dirs.dist\" can't both be "+
+			"\"%s\"", filepath.ToSlash(cfg.Dirs.Dev))
+	}
+	if isWithin(cfg.Dirs.Dev, cfg.Dirs.Dist) ||
+		isWithin(cfg.Dirs.Dist, cfg.Dirs.Dev) {
+		return fmt.Errorf("\"dirs.dev\" (\"%s\") and \"dirs.dist\" "+
+			"(\"%s\") can't be within one another",
+			filepath.ToSlash(cfg.Dirs.Dev), filepath.ToSlash(cfg.Dirs.Dist))
+	}
+
+	for _, name := range cfg.Build.Safelist {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("\"build.safelist\" can't contain empty names")
+		}
+	}
+	for _, asset := range cfg.Build.Assets {
+		if strings.TrimSpace(asset) == "" {
+			return errors.New("\"build.assets\" can't contain empty paths")
+		}
+	}
+
+	var rules []string
+	for rule := range cfg.Validate.Rules {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		if !contains(configurableRules, rule) {
+			return fmt.Errorf("unknown rule \"%s\" in \"validate.rules\", "+
+				"expected one of: %s", rule,
+				strings.Join(configurableRules, ", "))
+		}
+		switch cfg.Validate.Rules[rule] {
+		case "error", "warning", "off":
+		default:
+			return fmt.Errorf("\"validate.rules.%s\" should be \"error\", "+
+				"\"warning\" or \"off\", not \"%s\"", rule,
+				cfg.Validate.Rules[rule])
+		}
+	}
+	return nil
+}
+
+// Returns whether the path dir is within the directory parent, where both
+// are clean and relative to the same directory.
+func isWithin(dir string, parent string) bool {
+	return strings.HasPrefix(dir, parent+string(filepath.Separator))
+}
+
+// Applies the severity that webes.json gives d's rule, if it gives one.
+// Returns false if the rule is turned off.
+func (cfg projectConfig) applyRule(d lib.Diagnostic) (lib.Diagnostic, bool) {
+	switch severity := cfg.Validate.Rules[d.Rule]; severity {
+	case "off":
+		return d, false
+	case "error", "warning":
+		d.Severity = severity
+	}
+	return d, true
+}
+
+// Writes the default settings to the webes.json of the project, unless it
+// already has one.
+func writeDefaultConfig() error {
+	var path string = filepath.Join(project.root, configFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(defaultConfig(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
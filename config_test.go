@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestConfigCheckDirs(t *testing.T) {
+	var tests = []struct {
+		dev, dist string
+		ok        bool
+	}{
+		{"dev", "dist", true},
+		{"src/dev", "./out/", true},
+		{"dev", "devel", true},
+		{"", "dist", false},
+		{"dev", "dev/", false},
+		{".", "dist", false},
+		{"dev", "..", false},
+		{"../dev", "dist", false},
+		{"dev", "a/../../dist", false},
+		{"/tmp/dev", "dist", false},
+		{"dev", "dev/dist", false},
+		{"site/dist/dev", "site/dist", false},
+	}
+	for _, test := range tests {
+		var cfg projectConfig = defaultConfig()
+		cfg.Dirs = dirsConfig{Dev: test.dev, Dist: test.dist}
+		if err := cfg.check(); (err == nil) != test.ok {
+			t.Errorf("dirs %q and %q: got %v, want ok = %v", test.dev,
+				test.dist, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"  // Used for unwrapping errors
+	"fmt"     // Used for building messages
+	"os"      // Used for telling missing files apart
+	"strings" // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// Every problem that webes reports has a stable code: warnings start with W,
+// problems with components and pages with E2, and problems with files and the
+// project with E3. `webes explain <code>` describes each of them along with
+// how to fix it, and codes never change meaning, so they can be searched for.
+
+// A problem that stops a command, which is rendered as a diagnostic (with a
+// code frame, if it points into a file).
+type webesError struct {
+	lib.Diagnostic
+	err error // what caused the problem, if it came from elsewhere
+}
+
+func (e *webesError) Error() string {
+	return e.Diagnostic.String()
+}
+
+func (e *webesError) Unwrap() error {
+	return e.err
+}
+
+// Returns an error with code, whose message is format filled in with args.
+func newError(code string, format string, args ...interface{}) *webesError {
+	return &webesError{Diagnostic: lib.Diagnostic{Rule: codeRule(code),
+		Code: code, Severity: "error", Message: fmt.Sprintf(format, args...)}}
+}
+
+// Returns err with code, unless it already has a code of its own.
+func withCode(code string, err error) error {
+	var webesErr *webesError
+	if err == nil || errors.As(err, &webesErr) {
+		return err
+	}
+	return &webesError{Diagnostic: lib.Diagnostic{Rule: codeRule(code),
+		Code: code, Severity: "error", Message: err.Error()}, err: err}
+}
+
+// Returns err pointing at path (within the project), unless it already
+// points at a file.
+func inFile(err error, path string) error {
+	var webesErr *webesError
+	if err == nil || !errors.As(err, &webesErr) {
+		if err != nil {
+			return fmt.Errorf("%s: %w", project.rel(path), err)
+		}
+		return nil
+	}
+	if webesErr.File == "" {
+		webesErr.File = project.rel(path)
+	}
+	return err
+}
+
+// Returns the error for a file or directory that couldn't be read, which is
+// friendlier about ones that are missing.
+func readError(path string, err error) error {
+	var e *webesError = newError("E301", "couldn't be read: %s",
+		errorReason(err))
+	if os.IsNotExist(err) {
+		e.Message = "doesn't exist"
+	}
+	e.File, e.err = project.rel(path), err
+	return e
+}
+
+// Returns the error for a file or directory that couldn't be written (or
+// removed).
+func writeError(path string, err error) error {
+	var e *webesError = newError("E302", "couldn't be written: %s",
+		errorReason(err))
+	e.File, e.err = project.rel(path), err
+	return e
+}
+
+// Returns why err happened, without the path that *os.PathError repeats.
+func errorReason(err error) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// Returns the code of err, or "" if it has none.
+func errorCode(err error) string {
+	var webesErr *webesError
+	if errors.As(err, &webesErr) {
+		return webesErr.Code
+	}
+	return ""
+}
+
+// Returns the diagnostic of err, for reporting it next to other diagnostics
+// (as validate does). Errors without a code of their own get code.
+func errorDiagnostic(err error, code string) lib.Diagnostic {
+	var webesErr *webesError
+	if !errors.As(withCode(code, err), &webesErr) {
+		return lib.Diagnostic{}
+	}
+	return webesErr.Diagnostic
+}
+
+// A code that webes reports problems with, as `webes explain` describes it.
+type diagnosticCode struct {
+	code        string
+	rule        string // the rule that validate reports it under
+	title       string
+	explanation string
+	example     string // how the problem looks, and how it's fixed
+}
+
+var diagnosticCodes = []diagnosticCode{
+	{"W101", "unused-class", "Unused class",
+		"A class is only found in one section of a component: styled but " +
+			"never used in its template, or used in its template but never " +
+			"styled. Unused selectors are left out of dist/.",
+		"<template><h1 class=\"title\">Hi</h1></template>\n" +
+			"<style>.titel { color: red; }</style>\n\n" +
+			"Fix the typo so that both say \"title\", remove the class, or " +
+			"safelist it if it's added by the script:\n" +
+			"<style>/* webes-safelist: titel */</style>"},
+	{"W102", "unused-id", "Unused id",
+		"An id is only found in one section of a component.",
+		"<template><div id=\"menu\"></div></template>\n" +
+			"<style>#nav { display: flex; }</style>\n\n" +
+			"Use the same id in both, or remove the one that's left over."},
+	{"W103", "unused-function", "Unused function",
+		"A function defined by a component's script is never called by its " +
+			"template or script, or the template calls a function that the " +
+			"script doesn't define. Uncalled functions are left out of dist/.",
+		"<template><button onclick=\"opn()\">Open</button></template>\n" +
+			"<script>function open() {}</script>\n\n" +
+			"Call the function by its name, or safelist it if something " +
+			"outside of the component calls it:\n" +
+			"<script>// webes-safelist: open</script>"},
+	{"W104", "unused-prop", "Unused prop",
+		"A prop is declared in a component's <props> but its template never " +
+			"uses it, or the template uses {{ name }} without declaring it.",
+		"<props>title: string</props>\n" +
+			"<template><h1>{{ heading }}</h1></template>\n\n" +
+			"Use the declared name in the template: <h1>{{ title }}</h1>"},
+	{"W105", "unused-asset", "Unused file",
+		"A file within dev/imgs, dev/scripts or dev/styles isn't referred to " +
+			"by any page (or stylesheet), so it isn't copied into dist/.",
+		"dev/imgs/old-logo.png is never used by any <img src>, <link href> " +
+			"or url().\n\n" +
+			"Delete the file, refer to it from a page, or turn the rule off " +
+			"in webes.json: \"validate\": {\"rules\": {\"unused-asset\": " +
+			"\"off\"}}"},
+	{"W106", "unknown-slot", "Unknown slot",
+		"A component is given content for a named slot that its template " +
+			"doesn't have, so the content would be dropped.",
+		"<Card><h2 slot=\"heading\">Hi</h2></Card>\n" +
+			"while _card.webes only has <slot name=\"title\"></slot>\n\n" +
+			"Use the slot's name: <h2 slot=\"title\">Hi</h2>"},
+	{"E201", "component-error", "Component not found",
+		"A page or component uses a component (or layout) that doesn't " +
+			"exist within dev/components (or dev/layouts), or a " +
+			"<webes-include> or <webes-layout> is missing the attribute " +
+			"that names it.",
+		"<HeroBanner /> in a page, but there's no dev/components/" +
+			"_heroBanner.webes\n\n" +
+			"Create the component, or fix the name: components are used as " +
+			"<HeroBanner />, <hero-banner></hero-banner> or " +
+			"<webes-include src=\"_heroBanner\" />."},
+	{"E202", "component-error", "Component cycle",
+		"Components include each other in a loop, so they could never stop " +
+			"being expanded.",
+		"_a.webes includes <B />, and _b.webes includes <A />\n\n" +
+			"Remove one of the includes, e.g. by moving what both need into " +
+			"a third component."},
+	{"E203", "component-error", "Conflicting component names",
+		"Two components (or layouts) end up with the same name once case, " +
+			"hyphens and leading underscores are ignored, so tags can't tell " +
+			"them apart.",
+		"dev/components/_heroBanner.webes and dev/components/hero-banner.webes" +
+			"\n\nRename or remove one of them."},
+	{"E204", "prop-error", "Invalid props",
+		"A component is given a prop that it doesn't declare, is missing a " +
+			"required prop, or is given a value of the wrong type.",
+		"<Card count=\"many\" /> while _card.webes declares " +
+			"count: number\n\n" +
+			"Give a value of the declared type: <Card count=\"3\" />"},
+	{"E205", "component-error", "Invalid prop declaration",
+		"A line within a component's <props> can't be parsed. Each " +
+			"declaration looks like `name: type = default`, where the type is " +
+			"string, number or boolean, and a ? after the name makes it " +
+			"optional.",
+		"<props>\n\ttitle string\n</props>\n\n" +
+			"Separate the name and type with a colon:\n" +
+			"<props>\n\ttitle: string\n\tsubtitle?: string = \"\"\n</props>"},
+	{"E206", "placeholder", "Placeholder left in a page",
+		"A placeholder such as !YOUR_URL would end up in a built page, " +
+			"usually from old boilerplate.",
+		"<meta name=\"description\" content=\"!YOUR_DESCRIPTION\">\n\n" +
+			"Remove the tag, and fill it in through the page's front " +
+			"matter:\n---\ndescription: What the page is about\n---"},
+	{"E207", "front-matter-error", "Invalid front matter",
+		"A page's front matter has a key that webes doesn't know, or gives a " +
+			"key twice.",
+		"---\ntitel: Hello\n---\n\n" +
+			"Use one of: " + strings.Join(frontMatterKeys, ", ") +
+			"\n---\ntitle: Hello\n---"},
+	{"E208", "front-matter-error", "Front matter without a <head>",
+		"A page has front matter, but ends up without a <head> to put its " +
+			"metadata in, as neither it nor a layout provides one.",
+		"A page with front matter and no dev/layouts/default.webes\n\n" +
+			"Create dev/layouts/default.webes with a <head> and a <slot>, " +
+			"name a layout with `layout:` in the front matter, or give the " +
+			"page an <html> of its own."},
+	{"E209", "page-error", "Conflicting pages",
+		"Two pages would be built to the same file within dist/.",
+		"dev/pages/about.html and dev/pages/about.md both become " +
+			"dist/pages/about.html\n\nRename or remove one of them."},
+	{"E301", "read-error", "File can't be read",
+		"A file or directory that webes needs can't be read, usually " +
+			"because it doesn't exist.",
+		"dev/components: doesn't exist\n\n" +
+			"Run webes from within a project made by `webes init`, or create " +
+			"the missing directory."},
+	{"E302", "write-error", "File can't be written",
+		"A file or directory can't be written (or removed), e.g. within " +
+			"dist/ while building, because of its permissions or a full disk.",
+		"dist/index.html: couldn't be written: permission denied\n\n" +
+			"Check that you can write to dist/ (and that nothing else has " +
+			"the file locked), then build again."},
+	{"E303", "project-error", "Not within a project",
+		"The command works on a project, but neither the working directory " +
+			"nor any directory above it holds webes.json (or dev/ and dist/).",
+		"Running `webes build` in your home directory\n\n" +
+			"cd into the project first, or run `webes init` to create one."},
+	{"E304", "config-error", "Invalid webes.json",
+		"webes.json isn't valid JSON, has a key that webes doesn't know, or " +
+			"gives a setting a value that it doesn't accept.",
+		"{\"site\": {\"url\": \"example.com\"}}\n\n" +
+			"Give the site's URL along with its scheme:\n" +
+			"{\"site\": {\"url\": \"https://example.com\"}}"},
+	{"E305", "usage-error", "Page not found",
+		"`webes build --page` names a page that isn't within dev/pages.",
+		"webes build --page=abuot\n\n" +
+			"Give the page relative to dev/pages, with or without its " +
+			"extension: webes build --page=about"},
+}
+
+// Returns the description of code, e.g. "W101".
+func findCode(code string) (diagnosticCode, bool) {
+	for _, c := range diagnosticCodes {
+		if strings.EqualFold(c.code, code) {
+			return c, true
+		}
+	}
+	return diagnosticCode{}, false
+}
+
+// Returns the rule that code is reported under.
+func codeRule(code string) string {
+	c, _ := findCode(code)
+	return c.rule
+}
+
+// Returns the code of the diagnostics reported under rule, for the rules that
+// only have one.
+func ruleCode(rule string) string {
+	var found string
+	for _, c := range diagnosticCodes {
+		if c.rule != rule {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found = c.code
+	}
+	return found
+}
+
+// Returns every code, for shell completion.
+func codeNames() []string {
+	var names []string
+	for _, c := range diagnosticCodes {
+		names = append(names, c.code)
+	}
+	return names
+}
+
+// Describes a diagnostic code along with an example of how to fix it, or
+// lists every code
+// Callable via `webes explain [code]`
+func webes_explain(call *commandCall) error {
+	if call.arg(0) == "" {
+		lib.FmtPrint("Diagnostic Codes", "header")
+		for _, c := range diagnosticCodes {
+			fmt.Println(c.code + "  " + c.title)
+		}
+		fmt.Println()
+		fmt.Println("Run `webes explain <code>` for what one means and how " +
+			"to fix it.")
+		return nil
+	}
+	c, ok := findCode(call.arg(0))
+	if !ok {
+		return &usageError{call.name, "Unknown code \"" + call.arg(0) +
+			"\", run `webes explain` for every code"}
+	}
+	lib.FmtPrint(c.code+": "+c.title, "header")
+	fmt.Println(c.explanation)
+	fmt.Println()
+	fmt.Println("Example:")
+	for _, line := range strings.Split(c.example, "\n") {
+		fmt.Println("  " + line)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDiagnosticCodes(t *testing.T) {
+	var seen = make(map[string]bool)
+	for _, c := range diagnosticCodes {
+		if seen[c.code] {
+			t.Errorf("%s is described twice", c.code)
+		}
+		seen[c.code] = true
+		// SARIF output describes each rule through validateRules
+		if validateRules[c.rule] == "" {
+			t.Errorf("%s: rule %q has no description in validateRules",
+				c.code, c.rule)
+		}
+		if codeRule(c.code) != c.rule {
+			t.Errorf("codeRule(%q) = %q, want %q", c.code, codeRule(c.code),
+				c.rule)
+		}
+	}
+	for _, rule := range configurableRules {
+		if ruleCode(rule) == "" {
+			t.Errorf("rule %q has no code of its own", rule)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json" // Used for printing the graph as JSON
+	"errors"        // Used for telling missing directories apart
+	"fmt"           // Used for printing the graph
+	"io"            // Used for writing the graph
+	"io/fs"         // Used for telling missing directories apart
+	"os"            // Used for printing the graph
+	"sort"          // Used for ordering components by name
+	"strings"       // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// A component tag within a page or component, and where it was found.
+type include struct {
+	component *component
+	pos       lib.Pos // within the file of the page or component
+	length    int     // the length of the start of the tag, e.g. "<Card"
+}
+
+// Which components each page and each component includes directly, in order
+// of first use. Tags that don't name an existing component are left out,
+// since expanding the page or component reports those.
+type depGraph struct {
+	components   []*component // sorted by name
+	byKey        map[string]*component
+	includes     map[*component][]include
+	pages        []page
+	pageIncludes map[string][]include // page src -> the components it includes
+}
+
+// Builds the graph of which components include which.
+func newDepGraph(components map[string]*component) *depGraph {
+	var g = &depGraph{
+		byKey:        components,
+		includes:     make(map[*component][]include),
+		pageIncludes: make(map[string][]include),
+	}
+	for _, c := range components {
+		g.components = append(g.components, c)
+	}
+	sort.Slice(g.components, func(i, j int) bool {
+		return g.components[i].name < g.components[j].name
+	})
+	for _, c := range g.components {
+		c := c
+		g.includes[c] = findIncludes(lib.ParseHTML(c.template), components,
+			func(offset int) lib.Pos {
+				return c.pos("template", offset)
+			})
+	}
+	return g
+}
+
+// Adds pages to the graph, along with the components they include directly.
+func (g *depGraph) addPages(pages []page) error {
+	for _, p := range pages {
+		src, err := readPage(p)
+		if err != nil {
+			return err
+		}
+		g.pages = append(g.pages, p)
+		g.pageIncludes[p.src] = findIncludes(parsePage(src, g.byKey),
+			g.byKey, src.lines.Pos)
+	}
+	return nil
+}
+
+// Returns every component that doc includes directly (content passed to a
+// component's slots included), where pos maps offsets within doc to positions
+// within its file.
+func findIncludes(doc *lib.HTMLNode, components map[string]*component,
+	pos func(int) lib.Pos) []include {
+	var includes []include
+	doc.Walk(func(n *lib.HTMLNode) bool {
+		c, ok, _ := lookupComponent(n, components)
+		if ok && findInclude(includes, c) == nil {
+			includes = append(includes, include{c, pos(n.Pos.Offset),
+				len("<" + n.Name)})
+		}
+		return true
+	})
+	return includes
+}
+
+// Returns the include of c within includes, or nil if there's none.
+func findInclude(includes []include, c *component) *include {
+	for i := range includes {
+		if includes[i].component == c {
+			return &includes[i]
+		}
+	}
+	return nil
+}
+
+// Returns a chain of components that leads from a component back to itself,
+// such as [_a, _b, _a] when _a includes _b and _b includes _a. Returns nil
+// if no component (directly or indirectly) includes itself.
+func (g *depGraph) findCycle() []*component {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var state = make(map[*component]int)
+	var stack []*component
+	var cycle []*component
+
+	var visit func(c *component) bool
+	visit = func(c *component) bool {
+		state[c] = visiting
+		stack = append(stack, c)
+		for _, inc := range g.includes[c] {
+			switch state[inc.component] {
+			case visiting:
+				for i, other := range stack {
+					if other == inc.component {
+						cycle = append(append([]*component{}, stack[i:]...),
+							inc.component)
+						break
+					}
+				}
+				return true
+			case 0:
+				if visit(inc.component) {
+					return true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[c] = visited
+		return false
+	}
+
+	for _, c := range g.components {
+		if state[c] == 0 && visit(c) {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Returns the error for cycle, as found by findCycle(), pointing at each
+// include along the way.
+func (g *depGraph) cycleError(cycle []*component) error {
+	var msg strings.Builder
+	msg.WriteString("component cycle: " + componentChain(cycle))
+	for i := 0; i+1 < len(cycle); i++ {
+		inc := findInclude(g.includes[cycle[i]], cycle[i+1])
+		fmt.Fprintf(&msg, "\n  %s:%d:%d: %s includes %s",
+			project.rel(cycle[i].path), inc.pos.Line, inc.pos.Col,
+			cycle[i].name, cycle[i+1].name)
+	}
+	return newError("E202", "%s", msg.String())
+}
+
+// Returns the names of the components in chain joined by arrows, e.g.
+// "_a -> _b -> _a".
+func componentChain(chain []*component) string {
+	var names []string
+	for _, c := range chain {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " -> ")
+}
+
+// Returns the components through which includes, those of a page, lead to c:
+// starting with the one that the page includes itself and ending with c.
+// Returns nil if they don't lead to c at all.
+func (g *depGraph) pathTo(includes []include, c *component) []*component {
+	// A breadth-first search, so that the shortest path is found
+	var from = make(map[*component]*component)
+	var queue []*component
+	for _, inc := range includes {
+		if _, ok := from[inc.component]; !ok {
+			from[inc.component] = nil
+			queue = append(queue, inc.component)
+		}
+	}
+	for len(queue) > 0 {
+		var next *component = queue[0]
+		queue = queue[1:]
+		if next == c {
+			var path []*component
+			for ; next != nil; next = from[next] {
+				path = append([]*component{next}, path...)
+			}
+			return path
+		}
+		for _, inc := range g.includes[next] {
+			if _, ok := from[inc.component]; !ok {
+				from[inc.component] = next
+				queue = append(queue, inc.component)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns every page that ends up using c, directly or through other
+// components.
+func (g *depGraph) pagesUsing(c *component) []page {
+	var pages []page
+	for _, p := range g.pages {
+		if g.pathTo(g.pageIncludes[p.src], c) != nil {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
+
+// Loads the components and pages of the project into a graph.
+func loadDepGraph() (*depGraph, error) {
+	components, err := loadComponents()
+	if err != nil {
+		return nil, err
+	}
+	var g *depGraph = newDepGraph(components)
+	pages, err := findPages()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := g.addPages(pages); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Prints which pages and components include which components, as a tree
+// (the default), as Graphviz DOT, or as JSON.
+// Callable via `webes graph [--format=tree|dot|json]`
+func webes_graph(call *commandCall) error {
+	g, err := loadDepGraph()
+	if err != nil {
+		return err
+	}
+	switch call.stringFlag("format") {
+	case "tree":
+		g.writeTree(os.Stdout)
+	case "dot":
+		g.writeDOT(os.Stdout)
+	case "json":
+		out, err := g.marshalJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	}
+	return nil
+}
+
+// Writes every page along with the tree of components that it includes,
+// followed by the components that no page uses.
+func (g *depGraph) writeTree(w io.Writer) {
+	var reached = make(map[*component]bool)
+	var branch func(includes []include, prefix string, ancestors []*component)
+	branch = func(includes []include, prefix string, ancestors []*component) {
+		for i, inc := range includes {
+			var connector, indent string = "├── ", "│   "
+			if i == len(includes)-1 {
+				connector, indent = "└── ", "    "
+			}
+			reached[inc.component] = true
+			if containsComponent(ancestors, inc.component) {
+				fmt.Fprintln(w, prefix+connector+inc.component.name+" (cycle)")
+				continue
+			}
+			fmt.Fprintln(w, prefix+connector+inc.component.name)
+			branch(g.includes[inc.component], prefix+indent,
+				append(ancestors, inc.component))
+		}
+	}
+
+	for _, p := range g.pages {
+		fmt.Fprintln(w, project.rel(p.src))
+		branch(g.pageIncludes[p.src], "", nil)
+	}
+	for _, c := range g.components {
+		if reached[c] {
+			continue
+		}
+		fmt.Fprintln(w, project.rel(c.path)+" (used by no page)")
+		branch(g.includes[c], "", []*component{c})
+	}
+}
+
+// Writes the graph in Graphviz's DOT language, e.g. for
+// `webes graph --format=dot | dot -Tsvg > graph.svg`.
+func (g *depGraph) writeDOT(w io.Writer) {
+	fmt.Fprintln(w, "digraph webes {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, p := range g.pages {
+		var name string = project.rel(p.src)
+		fmt.Fprintf(w, "\t%q [shape=note];\n", name)
+		for _, inc := range g.pageIncludes[p.src] {
+			fmt.Fprintf(w, "\t%q -> %q;\n", name, inc.component.name)
+		}
+	}
+	for _, c := range g.components {
+		fmt.Fprintf(w, "\t%q;\n", c.name)
+		for _, inc := range g.includes[c] {
+			fmt.Fprintf(w, "\t%q -> %q;\n", c.name, inc.component.name)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+type graphJSON struct {
+	Pages      []graphPageJSON      `json:"pages"`
+	Components []graphComponentJSON `json:"components"`
+}
+
+type graphPageJSON struct {
+	Path     string   `json:"path"`
+	Includes []string `json:"includes"`
+}
+
+type graphComponentJSON struct {
+	Name     string   `json:"name"`
+	Tag      string   `json:"tag"`
+	Path     string   `json:"path"`
+	Includes []string `json:"includes"`
+	UsedBy   []string `json:"usedBy"` // every page that ends up using it
+}
+
+// Returns the graph as JSON, with the pages and components each listing the
+// names of the components that they include directly.
+func (g *depGraph) marshalJSON() ([]byte, error) {
+	names := func(includes []include) []string {
+		var names = []string{}
+		for _, inc := range includes {
+			names = append(names, inc.component.name)
+		}
+		return names
+	}
+
+	var out = graphJSON{Pages: []graphPageJSON{},
+		Components: []graphComponentJSON{}}
+	for _, p := range g.pages {
+		out.Pages = append(out.Pages, graphPageJSON{project.rel(p.src),
+			names(g.pageIncludes[p.src])})
+	}
+	for _, c := range g.components {
+		var usedBy = []string{}
+		for _, p := range g.pagesUsing(c) {
+			usedBy = append(usedBy, project.rel(p.src))
+		}
+		out.Components = append(out.Components, graphComponentJSON{c.name,
+			componentTag(c.name), project.rel(c.path),
+			names(g.includes[c]), usedBy})
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// Lists every page that ends up using a component, along with the components
+// it's used through when the page doesn't include it directly.
+// Callable via `webes why <component>`, where the component can be given as
+// e.g. `_helloWorld`, `HelloWorld`, or `hello-world`.
+func webes_why(call *commandCall) error {
+	g, err := loadDepGraph()
+	if err != nil {
+		return err
+	}
+	c, ok := g.byKey[componentKey(call.arg(0))]
+	if !ok {
+		return newError("E201", "component \"%s\" not found in "+
+			"dev/components", call.arg(0))
+	}
+
+	var pages []page = g.pagesUsing(c)
+	if len(pages) == 0 {
+		lib.FmtPrint("No page uses "+c.name, "info")
+		return nil
+	}
+	for _, p := range pages {
+		var path []*component = g.pathTo(g.pageIncludes[p.src], c)
+		if len(path) == 1 {
+			fmt.Println(project.rel(p.src))
+			continue
+		}
+		fmt.Println(project.rel(p.src) + " (through " +
+			componentChain(path[:len(path)-1]) + ")")
+	}
+	return nil
+}
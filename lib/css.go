@@ -0,0 +1,664 @@
+package lib
+
+import "strings"
+
+// A single rule of a stylesheet.
+//
+// Style rules (`h1, .title { ... }`) have their selector list parsed into
+// Selectors, and their declarations into Declarations. At-rules have a Name
+// ("media", "keyframes", "font-face", ...) and Params (`screen and (...)`).
+// At-rules that group other rules (`@media`, `@supports`, `@keyframes`, ...)
+// keep those in Rules, the likes of `@font-face` have Declarations instead,
+// and at-rules without a block (`@import "x.css";`) have neither.
+type CSSRule struct {
+	Prelude      string // the selector list or at-rule, as written
+	Name         string // at-rule name, without the "@"
+	Params       string
+	Selectors    []*CSSSelector
+	Declarations []*CSSDeclaration
+	Rules        []*CSSRule
+	HasBlock     bool
+	Pos          Pos
+}
+
+// A single selector of a selector list, such as `ul > li.item:hover`, made up
+// of compound selectors (`ul`, `li.item:hover`) joined by combinators.
+type CSSSelector struct {
+	Compounds []*CSSCompound
+	Pos       Pos
+}
+
+// A compound selector, such as `li.item:hover`, along with the combinator
+// (" ", ">", "+" or "~") that joins it to the compound before it.
+type CSSCompound struct {
+	Combinator string
+	Simple     []*CSSSimpleSelector
+}
+
+// The types of simple selectors.
+type CSSSimpleType int
+
+const (
+	CSSTypeSelector      CSSSimpleType = iota // h1
+	CSSUniversalSelector                      // *
+	CSSClassSelector                          // .title
+	CSSIDSelector                             // #main
+	CSSAttributeSelector                      // [type="text"]
+	CSSPseudoClass                            // :hover, :not(.a)
+	CSSPseudoElement                          // ::before
+	CSSNestingSelector                        // &
+)
+
+// A single simple selector. Name is the class, id, element, attribute, or
+// pseudo name with any escapes resolved, while Raw is the selector as written.
+// Pseudo-classes that take selectors (`:not(...)`, `:is(...)`, ...) have
+// them parsed into Args.
+type CSSSimpleSelector struct {
+	Type CSSSimpleType
+	Name string
+	Raw  string
+	Args []*CSSSelector
+	Pos  Pos
+}
+
+// A single `property: value` declaration.
+type CSSDeclaration struct {
+	Property  string
+	Value     string
+	Important bool
+	Pos       Pos
+}
+
+// At-rules whose blocks contain rules rather than declarations.
+var groupingAtRules = []string{"media", "supports", "document", "layer",
+	"container", "scope", "keyframes", "-webkit-keyframes", "-moz-keyframes",
+	"-o-keyframes"}
+
+// Pseudo-classes whose arguments are selectors.
+var selectorPseudoClasses = []string{"not", "is", "where", "has", "matches",
+	"-webkit-any", "-moz-any", "host", "host-context", "slotted"}
+
+// Parses css into its rules. Comments are dropped, and positions are relative
+// to the start of css.
+func ParseCSS(css string) []*CSSRule {
+	var p = cssParser{src: css, lines: NewLineIndex(css)}
+	return p.parseRules(false)
+}
+
+type cssParser struct {
+	src   string
+	lines *LineIndex
+	pos   int
+}
+
+// Parses rules until either the end of the source or an unmatched "}" is
+// found. Selectors aren't parsed within @keyframes, as "from" and "50%"
+// aren't selectors.
+func (p *cssParser) parseRules(inKeyframes bool) []*CSSRule {
+	var rules []*CSSRule
+
+	for {
+		p.pos = skipCSSSpace(p.src, p.pos)
+		if p.pos >= len(p.src) {
+			return rules
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			return rules
+		}
+		if p.src[p.pos] == ';' {
+			p.pos++
+			continue
+		}
+		rules = append(rules, p.parseRule(inKeyframes))
+	}
+}
+
+// Parses a single rule, starting at its prelude.
+func (p *cssParser) parseRule(inKeyframes bool) *CSSRule {
+	var rule = &CSSRule{Pos: p.lines.Pos(p.pos)}
+	var start int = p.pos
+	for p.pos < len(p.src) && strings.IndexByte("{;}", p.src[p.pos]) == -1 {
+		p.pos = skipCSSToken(p.src, p.pos)
+	}
+	var prelude string = blankCSSComments(p.src[start:p.pos])
+	rule.Prelude = strings.Join(strings.Fields(prelude), " ")
+
+	if strings.HasPrefix(rule.Prelude, "@") {
+		fields := strings.SplitN(rule.Prelude[1:], " ", 2)
+		rule.Name = strings.ToLower(fields[0])
+		if len(fields) == 2 {
+			rule.Params = fields[1]
+		}
+	} else if !inKeyframes {
+		rule.Selectors = parseSelectorList(prelude, start, p.lines)
+	}
+
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		// A block-less at-rule, which is ended by a ";"
+		if p.pos < len(p.src) && p.src[p.pos] == ';' {
+			p.pos++
+		}
+		return rule
+	}
+	p.pos++
+	rule.HasBlock = true
+	if rule.Name != "" && containsString(groupingAtRules, rule.Name) {
+		rule.Rules = p.parseRules(strings.HasSuffix(rule.Name, "keyframes"))
+	} else {
+		rule.Declarations, rule.Rules = p.parseBlock(inKeyframes)
+	}
+	return rule
+}
+
+// Parses the contents of a style rule's block up to its closing "}" into
+// declarations, along with any nested rules.
+func (p *cssParser) parseBlock(inKeyframes bool) ([]*CSSDeclaration,
+	[]*CSSRule) {
+	var declarations []*CSSDeclaration
+	var rules []*CSSRule
+
+	for {
+		p.pos = skipCSSSpace(p.src, p.pos)
+		if p.pos >= len(p.src) {
+			return declarations, rules
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			return declarations, rules
+		}
+		if p.src[p.pos] == ';' {
+			p.pos++
+			continue
+		}
+
+		// Anything that reaches a "{" before a ";" is a nested rule
+		var start int = p.pos
+		var depth int = 0
+		var end int = -1
+		for i := start; i < len(p.src) && end == -1; i = skipCSSToken(p.src, i) {
+			switch p.src[i] {
+			case '(', '[':
+				depth++
+			case ')', ']':
+				depth--
+			case ';', '}':
+				if depth <= 0 {
+					end = i
+				}
+			case '{':
+				if depth <= 0 {
+					rules = append(rules, p.parseRule(inKeyframes))
+					end = -2
+				}
+			}
+		}
+		if end == -2 {
+			continue
+		}
+		if end == -1 {
+			end = len(p.src)
+		}
+		p.pos = end
+
+		decl := parseDeclaration(blankCSSComments(p.src[start:end]), start,
+			p.lines)
+		if decl != nil {
+			declarations = append(declarations, decl)
+		}
+	}
+}
+
+// Parses `property: value !important`, which starts at offset within the
+// source. Returns nil if text isn't a declaration.
+func parseDeclaration(text string, offset int, lines *LineIndex) *CSSDeclaration {
+	colon := strings.IndexByte(text, ':')
+	if colon == -1 {
+		return nil
+	}
+	var decl = &CSSDeclaration{
+		Property: strings.TrimSpace(text[:colon]),
+		Value:    strings.TrimSpace(text[colon+1:]),
+		Pos:      lines.Pos(offset),
+	}
+	if decl.Property == "" {
+		return nil
+	}
+	if idx := strings.LastIndex(decl.Value, "!"); idx != -1 &&
+		strings.EqualFold(strings.TrimSpace(decl.Value[idx+1:]), "important") {
+		decl.Important = true
+		decl.Value = strings.TrimSpace(decl.Value[:idx])
+	}
+	return decl
+}
+
+// Parses a selector list, which starts at offset within the source.
+func parseSelectorList(list string, offset int, lines *LineIndex) []*CSSSelector {
+	var selectors []*CSSSelector
+	var depth int = 0
+	var start int = 0
+
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '(', '[':
+				depth++
+				continue
+			case ')', ']':
+				depth--
+				continue
+			case '"', '\'', '\\':
+				i = skipCSSToken(list, i) - 1
+				continue
+			case ',':
+				if depth != 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if sel := parseSelector(list[start:i], offset+start, lines); sel != nil {
+			selectors = append(selectors, sel)
+		}
+		start = i + 1
+	}
+	return selectors
+}
+
+// Parses a single complex selector, which starts at offset within the source.
+func parseSelector(text string, offset int, lines *LineIndex) *CSSSelector {
+	var i int = 0
+	for i < len(text) && isCSSSpace(text[i]) {
+		i++
+	}
+	if i == len(text) {
+		return nil
+	}
+	var sel = &CSSSelector{Pos: lines.Pos(offset + i)}
+	var compound = &CSSCompound{}
+	var combinator string
+
+	for i < len(text) {
+		c := text[i]
+		if isCSSSpace(c) || c == '>' || c == '+' || c == '~' {
+			// A combinator, possibly surrounded by whitespace
+			for i < len(text) && isCSSSpace(text[i]) {
+				i++
+			}
+			combinator = " "
+			if i < len(text) && strings.IndexByte(">+~", text[i]) != -1 {
+				combinator = string(text[i])
+				i++
+				for i < len(text) && isCSSSpace(text[i]) {
+					i++
+				}
+			}
+			if len(compound.Simple) > 0 {
+				sel.Compounds = append(sel.Compounds, compound)
+				compound = &CSSCompound{Combinator: combinator}
+			} else {
+				compound.Combinator = combinator
+			}
+			continue
+		}
+
+		var simple = &CSSSimpleSelector{Pos: lines.Pos(offset + i)}
+		var start int = i
+		switch {
+		case c == '.':
+			simple.Type = CSSClassSelector
+			simple.Name, i = readCSSIdent(text, i+1)
+		case c == '#':
+			simple.Type = CSSIDSelector
+			simple.Name, i = readCSSIdent(text, i+1)
+		case c == '*':
+			simple.Type = CSSUniversalSelector
+			simple.Name = "*"
+			i++
+		case c == '&':
+			simple.Type = CSSNestingSelector
+			simple.Name = "&"
+			i++
+		case c == '[':
+			simple.Type = CSSAttributeSelector
+			end := i + 1
+			for end < len(text) && text[end] != ']' {
+				end = skipCSSToken(text, end)
+			}
+			simple.Name, _ = readCSSIdent(text, skipSpaceIn(text, i+1))
+			i = end + 1
+			if i > len(text) {
+				i = len(text)
+			}
+		case c == ':':
+			simple.Type = CSSPseudoClass
+			i++
+			if i < len(text) && text[i] == ':' {
+				simple.Type = CSSPseudoElement
+				i++
+			}
+			simple.Name, i = readCSSIdent(text, i)
+			simple.Name = strings.ToLower(simple.Name)
+			// Legacy pseudo-elements that are written with a single ":"
+			switch simple.Name {
+			case "before", "after", "first-line", "first-letter":
+				simple.Type = CSSPseudoElement
+			}
+			if i < len(text) && text[i] == '(' {
+				var argsStart int = i + 1
+				var depth int = 0
+				for ; i < len(text); i = skipCSSToken(text, i) {
+					if text[i] == '(' {
+						depth++
+					} else if text[i] == ')' {
+						depth--
+						if depth == 0 {
+							break
+						}
+					}
+				}
+				if containsString(selectorPseudoClasses, simple.Name) {
+					simple.Args = parseSelectorList(text[argsStart:i],
+						offset+argsStart, lines)
+				}
+				if i < len(text) {
+					i++
+				}
+			}
+		default:
+			simple.Type = CSSTypeSelector
+			simple.Name, i = readCSSIdent(text, i)
+			if i == start {
+				// Not part of any selector we know of, e.g. a "%" in a
+				// keyframe selector that ended up here; keep it as-is.
+				i++
+				simple.Name = text[start:i]
+			}
+		}
+		simple.Raw = text[start:i]
+		compound.Simple = append(compound.Simple, simple)
+	}
+	if len(compound.Simple) > 0 {
+		sel.Compounds = append(sel.Compounds, compound)
+	}
+	return sel
+}
+
+// Reads the identifier starting at text[i], resolving any escapes. Returns
+// the identifier and the index right after it.
+func readCSSIdent(text string, i int) (string, int) {
+	var ident strings.Builder
+	for i < len(text) {
+		c := text[i]
+		if c == '\\' && i+1 < len(text) {
+			// Hex escapes (\31 ) and escaped characters (\:)
+			var end int = i + 1
+			for end < len(text) && end < i+7 && isHexDigit(text[end]) {
+				end++
+			}
+			if end > i+1 {
+				var r rune = 0
+				for _, h := range text[i+1 : end] {
+					r = r*16 + hexValue(byte(h))
+				}
+				ident.WriteRune(r)
+				if end < len(text) && isCSSSpace(text[end]) {
+					end++
+				}
+				i = end
+			} else {
+				ident.WriteByte(text[i+1])
+				i += 2
+			}
+			continue
+		}
+		if !isCSSIdentChar(c) {
+			break
+		}
+		ident.WriteByte(c)
+		i++
+	}
+	return ident.String(), i
+}
+
+// Renders rules back into CSS.
+func RenderCSS(rules []*CSSRule) string {
+	var out strings.Builder
+	renderCSSRules(&out, rules, "")
+	return out.String()
+}
+
+func renderCSSRules(out *strings.Builder, rules []*CSSRule, indent string) {
+	for _, rule := range rules {
+		out.WriteString(indent + rule.RenderPrelude())
+		if !rule.HasBlock {
+			out.WriteString(";\n")
+			continue
+		}
+		out.WriteString(" {")
+		if len(rule.Declarations) > 0 {
+			var declarations []string
+			for _, decl := range rule.Declarations {
+				declarations = append(declarations, decl.String())
+			}
+			out.WriteString(" " + strings.Join(declarations, "; ") + ";")
+		}
+		if len(rule.Rules) > 0 {
+			out.WriteString("\n")
+			renderCSSRules(out, rule.Rules, indent+"\t")
+			out.WriteString(indent)
+		} else if len(rule.Declarations) > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString("}\n")
+	}
+}
+
+// Returns the rule's prelude, rendered from its selectors for style rules.
+func (rule *CSSRule) RenderPrelude() string {
+	if rule.Selectors == nil {
+		return rule.Prelude
+	}
+	var selectors []string
+	for _, sel := range rule.Selectors {
+		selectors = append(selectors, sel.String())
+	}
+	return strings.Join(selectors, ", ")
+}
+
+// Returns whether the rule is a @keyframes rule.
+func (rule *CSSRule) IsKeyframes() bool {
+	return strings.HasSuffix(rule.Name, "keyframes")
+}
+
+// Calls fn for every rule in rules, and every rule nested within them.
+func WalkCSS(rules []*CSSRule, fn func(*CSSRule)) {
+	for _, rule := range rules {
+		fn(rule)
+		WalkCSS(rule.Rules, fn)
+	}
+}
+
+// Renders the selector back into CSS.
+func (sel *CSSSelector) String() string {
+	var out strings.Builder
+	for i, compound := range sel.Compounds {
+		if i > 0 {
+			if compound.Combinator == " " || compound.Combinator == "" {
+				out.WriteString(" ")
+			} else {
+				out.WriteString(" " + compound.Combinator + " ")
+			}
+		}
+		for _, simple := range compound.Simple {
+			out.WriteString(simple.Raw)
+		}
+	}
+	return out.String()
+}
+
+// Returns every simple selector of sel, including those within the arguments
+// of pseudo-classes such as `:is(...)`. The arguments of `:not(...)` are left
+// out, as they never have to match anything for the selector to match.
+func (sel *CSSSelector) SimpleSelectors() []*CSSSimpleSelector {
+	var simples []*CSSSimpleSelector
+	for _, compound := range sel.Compounds {
+		for _, simple := range compound.Simple {
+			simples = append(simples, simple)
+			if simple.Name == "not" {
+				continue
+			}
+			for _, arg := range simple.Args {
+				simples = append(simples, arg.SimpleSelectors()...)
+			}
+		}
+	}
+	return simples
+}
+
+// Renders the declaration back into CSS, without a trailing ";".
+func (decl *CSSDeclaration) String() string {
+	var important string
+	if decl.Important {
+		important = " !important"
+	}
+	return decl.Property + ": " + decl.Value + important
+}
+
+// Returns the index right after the token starting at css[i], where strings,
+// comments, and escaped characters count as a single token.
+func skipCSSToken(css string, i int) int {
+	switch {
+	case strings.HasPrefix(css[i:], "/*"):
+		end := strings.Index(css[i+2:], "*/")
+		if end == -1 {
+			return len(css)
+		}
+		return i + 2 + end + 2
+	case css[i] == '"' || css[i] == '\'':
+		for j := i + 1; j < len(css); j++ {
+			if css[j] == '\\' {
+				j++
+			} else if css[j] == css[i] || css[j] == '\n' {
+				return j + 1
+			}
+		}
+		return len(css)
+	case css[i] == '\\' && i+1 < len(css):
+		return i + 2
+	}
+	return i + 1
+}
+
+// Returns every URL that css refers to, through url() or @import, in order.
+// css may be a whole stylesheet or just declarations (as in a style
+// attribute).
+func CSSURLs(css string) []string {
+	var urls []string
+	for i := 0; i < len(css); {
+		if hasPrefixFold(css[i:], "url(") &&
+			(i == 0 || !isCSSIdentChar(css[i-1])) {
+			var j int = skipSpaceIn(css, i+len("url("))
+			var url string
+			if j < len(css) && (css[j] == '"' || css[j] == '\'') {
+				end := skipCSSToken(css, j)
+				url = strings.TrimRight(css[j+1:end], "\"'")
+				j = end
+			} else {
+				end := strings.IndexByte(css[j:], ')')
+				if end == -1 {
+					end = len(css) - j
+				}
+				url = css[j : j+end]
+				j += end
+			}
+			if url = strings.TrimSpace(url); url != "" {
+				urls = append(urls, url)
+			}
+			i = j
+			continue
+		}
+		if hasPrefixFold(css[i:], "@import") {
+			// `@import url("x.css")` is taken care of by the url() case
+			var j int = skipCSSSpace(css, i+len("@import"))
+			if j < len(css) && (css[j] == '"' || css[j] == '\'') {
+				end := skipCSSToken(css, j)
+				urls = append(urls, strings.TrimRight(css[j+1:end], "\"'"))
+				i = end
+				continue
+			}
+		}
+		i = skipCSSToken(css, i)
+	}
+	return urls
+}
+
+func hasPrefixFold(s string, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// Returns the index of the first character at or after i that is neither
+// whitespace nor part of a comment.
+func skipCSSSpace(css string, i int) int {
+	for i < len(css) {
+		if strings.HasPrefix(css[i:], "/*") {
+			i = skipCSSToken(css, i)
+		} else if isCSSSpace(css[i]) {
+			i++
+		} else {
+			break
+		}
+	}
+	return i
+}
+
+func skipSpaceIn(text string, i int) int {
+	for i < len(text) && isCSSSpace(text[i]) {
+		i++
+	}
+	return i
+}
+
+// Replaces every comment in css with spaces, so that offsets within css stay
+// the same.
+func blankCSSComments(css string) string {
+	var out []byte = []byte(css)
+	for i := 0; i < len(css); {
+		next := skipCSSToken(css, i)
+		if strings.HasPrefix(css[i:], "/*") {
+			for j := i; j < next; j++ {
+				if out[j] != '\n' {
+					out[j] = ' '
+				}
+			}
+		}
+		i = next
+	}
+	return string(out)
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f'
+}
+
+func isCSSIdentChar(c byte) bool {
+	return c == '-' || c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') ||
+		(c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) rune {
+	switch {
+	case c >= '0' && c <= '9':
+		return rune(c - '0')
+	case c >= 'a' && c <= 'f':
+		return rune(c-'a') + 10
+	}
+	return rune(c-'A') + 10
+}
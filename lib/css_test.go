@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCSS(t *testing.T) {
+	var tests = []struct {
+		css  string
+		want string // the rendered rules
+	}{
+		{"h1{color:red}", "h1 { color: red; }\n"},
+		{"/* a comment */ h1, .a > b:hover { color: red !important }",
+			"h1, .a > b:hover { color: red !important; }\n"},
+		{"a { background: url(\"a;b.png\"); content: '}' }",
+			"a { background: url(\"a;b.png\"); content: '}'; }\n"},
+		{"@media (max-width: 600px) { .x { a: b } }",
+			"@media (max-width: 600px) {\n\t.x { a: b; }\n}\n"},
+		{"@import \"a.css\";\n@font-face { font-family: X }",
+			"@import \"a.css\";\n@font-face { font-family: X; }\n"},
+		{"@keyframes spin { from { a: b } to { a: c } }",
+			"@keyframes spin {\n\tfrom { a: b; }\n\tto { a: c; }\n}\n"},
+	}
+	for _, test := range tests {
+		if got := RenderCSS(ParseCSS(test.css)); got != test.want {
+			t.Errorf("RenderCSS(ParseCSS(%q)) = %q, want %q", test.css, got,
+				test.want)
+		}
+	}
+}
+
+func TestParseCSSRules(t *testing.T) {
+	var rules []*CSSRule = ParseCSS("h1, h2 { a: b }\n@media print { p {} }\n" +
+		"@import \"x.css\";\n@font-face { font-family: X }")
+	if len(rules) != 4 {
+		t.Fatalf("got %d rules, want 4", len(rules))
+	}
+	var want = []struct {
+		name, params       string
+		selectors, decls   int
+		nested             int
+		hasBlock, keyframe bool
+	}{
+		{"", "", 2, 1, 0, true, false},
+		{"media", "print", 0, 0, 1, true, false},
+		{"import", "\"x.css\"", 0, 0, 0, false, false},
+		{"font-face", "", 0, 1, 0, true, false},
+	}
+	for i, rule := range rules {
+		w := want[i]
+		if rule.Name != w.name || rule.Params != w.params ||
+			len(rule.Selectors) != w.selectors ||
+			len(rule.Declarations) != w.decls || len(rule.Rules) != w.nested ||
+			rule.HasBlock != w.hasBlock || rule.IsKeyframes() != w.keyframe {
+			t.Errorf("rule %d (%q) doesn't match %+v", i, rule.Prelude, w)
+		}
+	}
+	if rules[1].Pos.Line != 2 {
+		t.Errorf("@media is on line %d, want 2", rules[1].Pos.Line)
+	}
+}
+
+// Returns the simple selectors of the first selector in css, as
+// "type:name" pairs.
+func simpleSelectors(css string) []string {
+	var names = map[CSSSimpleType]string{CSSTypeSelector: "type",
+		CSSUniversalSelector: "universal", CSSClassSelector: "class",
+		CSSIDSelector: "id", CSSAttributeSelector: "attr",
+		CSSPseudoClass: "pseudo-class", CSSPseudoElement: "pseudo-element",
+		CSSNestingSelector: "nesting"}
+	var out []string
+	for _, simple := range ParseCSS(css)[0].Selectors[0].SimpleSelectors() {
+		out = append(out, names[simple.Type]+":"+simple.Name)
+	}
+	return out
+}
+
+func TestCSSSelectors(t *testing.T) {
+	var tests = []struct {
+		css  string
+		want []string
+	}{
+		{"ul > li.item:hover {}", []string{"type:ul", "type:li", "class:item",
+			"pseudo-class:hover"}},
+		{"#main [type=\"text\"]::before {}", []string{"id:main",
+			"attr:type", "pseudo-element:before"}},
+		{".sm\\:hidden {}", []string{"class:sm:hidden"}},
+		{":is(.a, .b) {}", []string{"pseudo-class:is", "class:a",
+			"class:b"}},
+		// What :not() holds never has to match
+		{"p:not(.x) {}", []string{"type:p", "pseudo-class:not"}},
+		{"* + & {}", []string{"universal:*", "nesting:&"}},
+	}
+	for _, test := range tests {
+		if got := simpleSelectors(test.css); !reflect.DeepEqual(got,
+			test.want) {
+			t.Errorf("selectors of %q = %q, want %q", test.css, got,
+				test.want)
+		}
+	}
+}
+
+func TestCSSURLs(t *testing.T) {
+	var tests = []struct {
+		css  string
+		want []string
+	}{
+		{"@import 'a.css'; @import url(b.css);", []string{"a.css", "b.css"}},
+		{"a { background: url(img/a.png) } b { c: url( \"q.png\" ) }",
+			[]string{"img/a.png", "q.png"}},
+		{"background: url('x.png')", []string{"x.png"}},
+		{"/* url(no.png) */ a { content: 'url(no.png)' }", nil},
+	}
+	for _, test := range tests {
+		if got := CSSURLs(test.css); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("CSSURLs(%q) = %q, want %q", test.css, got, test.want)
+		}
+	}
+}
+
+func TestSelectorMatchesAny(t *testing.T) {
+	var doc *HTMLNode = ParseHTML("<nav class=\"menu\"><ul><li id=\"first\">" +
+		"<a href=\"/\" data-x=\"a b\">Home</a></li><li>b</li></ul></nav>" +
+		"<p>text</p>")
+	var tests = []struct {
+		selector string
+		want     bool
+	}{
+		{"nav", true},
+		{".menu li", true},
+		{".menu > li", false},
+		{"ul > li > a", true},
+		{"#first + li", true},
+		{"#first ~ p", false},
+		{"li ~ li", true},
+		{"p a", false},
+		{"a[href=\"/\"]", true},
+		{"a[data-x~=b]", true},
+		{"a[data-x^=c]", false},
+		{".missing", false},
+		{"a:hover", true},
+		{"li:nth-child(2)", true},
+		{"div:not(.x)", false},
+		{"NAV.menu", true},
+	}
+	for _, test := range tests {
+		var sel *CSSSelector = ParseCSS(test.selector + " {}")[0].Selectors[0]
+		if got := SelectorMatchesAny(doc, sel); got != test.want {
+			t.Errorf("SelectorMatchesAny(%q) = %v, want %v", test.selector,
+				got, test.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A problem found at a specific place within a source file.
+type Diagnostic struct {
+	Rule     string // identifies the kind of problem, e.g. "unused-class"
+	Code     string // the problem's stable code, e.g. "W101", if it has one
+	Severity string // "error", "warning" or "info", as accepted by Fmt()
+	Message  string
+	File     string
+	Pos      Pos
+	Len      int    // how many characters the offending span covers
+	Source   string // the whole file, used for rendering the code frame
+}
+
+// The number of lines shown before and after the offending line in a code
+// frame.
+const codeFrameContext = 1
+
+// Returns the diagnostic's location, code and message, e.g.
+// `dev/components/_card.webes:4:3: [W101] Found unused class "title" in style
+// of _card`
+func (d Diagnostic) String() string {
+	var message string = d.Message
+	if d.Code != "" {
+		message = "[" + d.Code + "] " + message
+	}
+	var location string = d.File
+	if d.Pos.Line > 0 {
+		location += ":" + strconv.Itoa(d.Pos.Line) + ":" +
+			strconv.Itoa(d.Pos.Col)
+	}
+	if location == "" {
+		return message
+	}
+	return location + ": " + message
+}
+
+// Returns the position right after the offending span. Spans never continue
+// past the end of their line.
+func (d Diagnostic) End() Pos {
+	var end Pos = d.Pos
+	if d.Source == "" || d.Pos.Line < 1 {
+		end.Col += d.Len
+		return end
+	}
+	for i := 0; i < d.Len && end.Offset < len(d.Source); i++ {
+		r, size := utf8.DecodeRuneInString(d.Source[end.Offset:])
+		if r == '\n' || r == '\r' {
+			break
+		}
+		end.Offset += size
+		end.Col++
+	}
+	return end
+}
+
+// Prints the diagnostic, styled according to its severity, followed by its
+// code frame (if it has one).
+func PrintDiagnostic(d Diagnostic) {
+	FprintDiagnostic(os.Stdout, d)
+}
+
+// Same as PrintDiagnostic, but prints to w.
+func FprintDiagnostic(w io.Writer, d Diagnostic) {
+	FmtFprint(w, d.String(), d.Severity)
+	if d.Source != "" && d.Pos.Line > 0 {
+		fmt.Fprint(w, CodeFrame(d.Source, d.Pos, d.Len))
+	}
+}
+
+// Renders the lines of src around pos, with carets under the length
+// characters that start at pos, e.g.
+//
+//	  3 | <template>
+//	> 4 |   <h1 class="title">Hello</h1>
+//	    |             ^^^^^
+//	  5 | </template>
+func CodeFrame(src string, pos Pos, length int) string {
+	var lines *LineIndex = NewLineIndex(src)
+	if pos.Line < 1 || pos.Line > lines.Lines() {
+		return ""
+	}
+	var first int = pos.Line - codeFrameContext
+	if first < 1 {
+		first = 1
+	}
+	var last int = pos.Line + codeFrameContext
+	if last > lines.Lines() {
+		last = lines.Lines()
+	}
+	var width int = len(strconv.Itoa(last))
+
+	var out strings.Builder
+	for n := first; n <= last; n++ {
+		var text string = lines.Line(n)
+		var marker string = "  "
+		if n == pos.Line {
+			marker = "> "
+		}
+		out.WriteString(Style("gray", marker+padLeft(strconv.Itoa(n), width)+
+			" | ") + text + "\n")
+		if n != pos.Line {
+			continue
+		}
+
+		// Tabs before the span are kept so that the carets line up with it
+		// however wide the terminal draws them.
+		var prefix []rune = []rune(text)
+		var col int = pos.Col - 1
+		if col > len(prefix) {
+			col = len(prefix)
+		}
+		var indent []rune = make([]rune, col)
+		for i := range indent {
+			indent[i] = ' '
+			if prefix[i] == '\t' {
+				indent[i] = '\t'
+			}
+		}
+		var span int = length
+		if span > len(prefix)-col {
+			span = len(prefix) - col
+		}
+		if span < 1 {
+			span = 1
+		}
+		out.WriteString(Style("gray", "  "+strings.Repeat(" ", width)+" | ") +
+			string(indent) + Style("red", strings.Repeat("^", span)) + "\n")
+	}
+	return out.String()
+}
+
+// Pads text with spaces on its left until it's width characters wide.
+func padLeft(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return strings.Repeat(" ", width-len(text)) + text
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A `key: value` line of a file's front matter.
+type FrontMatterField struct {
+	Key   string
+	Value string
+	Line  int // 1-based, within the whole file
+}
+
+// Splits the front matter off the start of src: the lines between a first
+// line of "---" and the next line of "---", each of them `key: value`. Values
+// can be wrapped in quotes, and blank lines as well as lines starting with #
+// are skipped. Returns the fields along with the offset that the rest of src
+// starts at, which is 0 if src has no front matter.
+func ParseFrontMatter(src string) ([]FrontMatterField, int, error) {
+	var idx *LineIndex = NewLineIndex(src)
+	if strings.TrimRight(idx.Line(1), " \t") != "---" {
+		return nil, 0, nil
+	}
+
+	var fields []FrontMatterField
+	for line := 2; line <= idx.Lines(); line++ {
+		var text string = strings.TrimSpace(idx.Line(line))
+		if text == "---" {
+			return fields, idx.Offset(line + 1), nil
+		}
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		// Keys can contain colons themselves (e.g. `og:type: article`), so
+		// the key ends at the first colon followed by a space
+		var key, value string
+		if sep := strings.Index(text, ": "); sep != -1 {
+			key, value = text[:sep], strings.TrimSpace(text[sep+2:])
+		} else if strings.HasSuffix(text, ":") {
+			key = text[:len(text)-1]
+		} else {
+			return nil, 0, fmt.Errorf("line %d of the front matter isn't "+
+				"`key: value`", line)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, 0, fmt.Errorf("line %d of the front matter is "+
+				"missing its key", line)
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d of the front matter has "+
+					"a badly quoted value", line)
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' &&
+			value[len(value)-1] == '\'' {
+			value = strings.Replace(value[1:len(value)-1], "''", "'", -1)
+		}
+		fields = append(fields, FrontMatterField{key, value, line})
+	}
+	return nil, 0, fmt.Errorf("the front matter starting on line 1 is never " +
+		"closed by a line of ---")
+}
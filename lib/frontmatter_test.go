@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	var tests = []struct {
+		src    string
+		fields []FrontMatterField
+		rest   string // what's left of src after the front matter
+		ok     bool
+	}{
+		{"<p>no front matter</p>", nil, "<p>no front matter</p>", true},
+		{"---\ntitle: Hello\n---\n<p>a</p>",
+			[]FrontMatterField{{"title", "Hello", 2}}, "<p>a</p>", true},
+		{"---\r\ntitle: Hi\r\n---\r\nbody",
+			[]FrontMatterField{{"title", "Hi", 2}}, "body", true},
+		{"---\n# a comment\n\nog:type: article\nempty:\n---\n",
+			[]FrontMatterField{{"og:type", "article", 4}, {"empty", "", 5}},
+			"", true},
+		{"---\na: \"x: \\\"y\\\"\"\nb: 'it''s'\nc: 'open\n---\n",
+			[]FrontMatterField{{"a", "x: \"y\"", 2}, {"b", "it's", 3},
+				{"c", "'open", 4}}, "", true},
+		{"---\ntitle Hello\n---\n", nil, "", false},
+		{"---\n: value\n---\n", nil, "", false},
+		{"---\na: \"bad \\q\"\n---\n", nil, "", false},
+		{"---\ntitle: never closed\n", nil, "", false},
+	}
+	for _, test := range tests {
+		fields, offset, err := ParseFrontMatter(test.src)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseFrontMatter(%q): error = %v, want ok = %v",
+				test.src, err, test.ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("ParseFrontMatter(%q) = %+v, want %+v", test.src, fields,
+				test.fields)
+		}
+		if rest := test.src[offset:]; rest != test.rest {
+			t.Errorf("ParseFrontMatter(%q) leaves %q, want %q", test.src, rest,
+				test.rest)
+		}
+	}
+}
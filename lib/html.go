@@ -0,0 +1,501 @@
+package lib
+
+import (
+	"html"
+	"strings"
+)
+
+// The types of nodes produced by ParseHTML().
+type HTMLNodeType int
+
+const (
+	HTMLDocumentNode HTMLNodeType = iota // the root returned by ParseHTML
+	HTMLElementNode
+	HTMLTextNode
+	HTMLCommentNode
+	HTMLDoctypeNode // <!DOCTYPE ...> and other <!...> / <?...> markup
+)
+
+// A single node of an HTML tree.
+type HTMLNode struct {
+	Type        HTMLNodeType
+	Name        string // tag name as written, for elements
+	Attrs       []*HTMLAttr
+	Data        string // source text of text, comment, and doctype nodes
+	Children    []*HTMLNode
+	Parent      *HTMLNode
+	SelfClosing bool // written as <name />
+	EndTag      bool // whether the element was closed by an explicit </name>
+	Pos         Pos
+}
+
+// A single attribute of an element. Value holds the attribute's value with
+// any character references decoded, while Raw holds it as written.
+type HTMLAttr struct {
+	Name     string
+	Value    string
+	Raw      string
+	Quote    byte // the quote the value was wrapped in, 0 if unquoted
+	HasValue bool
+	Pos      Pos // where the attribute's name starts
+	ValuePos Pos // where the attribute's value starts (inside of any quotes)
+}
+
+// Elements that never have any content or end tag.
+var voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img",
+	"input", "link", "meta", "param", "source", "track", "wbr"}
+
+// Elements whose content is text that is never parsed for tags. The content
+// of the first two is kept as-is, while character references are decoded in
+// the content of the others.
+var rawTextElements = []string{"script", "style", "textarea", "title"}
+
+// Elements that close an open <p>.
+var blockElements = []string{"address", "article", "aside", "blockquote",
+	"details", "div", "dl", "fieldset", "figcaption", "figure", "footer",
+	"form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hgroup", "hr",
+	"main", "menu", "nav", "ol", "p", "pre", "section", "table", "ul"}
+
+// Parses src into a tree of nodes. Parsing never fails: markup that can't be
+// understood is kept as text so that rendering the tree gives back src.
+//
+// Elements are closed the way browsers close them: void elements never have
+// content, <li>, <p>, <td>, and friends are closed by their siblings, and end
+// tags close any elements that were left open within them. Unlike browsers,
+// `<name />` closes any element, as that's how components are used.
+func ParseHTML(src string) *HTMLNode {
+	var p = htmlParser{src: src, lines: NewLineIndex(src)}
+	var root = &HTMLNode{Type: HTMLDocumentNode, Pos: p.lines.Pos(0)}
+	p.stack = []*HTMLNode{root}
+	p.parse()
+	return root
+}
+
+type htmlParser struct {
+	src   string
+	lines *LineIndex
+	pos   int
+	stack []*HTMLNode // open elements, starting with the document
+	text  int         // where the current run of text started, -1 if none
+}
+
+func (p *htmlParser) parse() {
+	p.text = -1
+	for p.pos < len(p.src) {
+		if p.src[p.pos] != '<' {
+			if p.text == -1 {
+				p.text = p.pos
+			}
+			p.pos++
+			continue
+		}
+
+		var start int = p.pos
+		var next byte = 0
+		if p.pos+1 < len(p.src) {
+			next = p.src[p.pos+1]
+		}
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "<!--"):
+			p.flushText()
+			end := strings.Index(p.src[p.pos+4:], "-->")
+			if end == -1 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += 4 + end + 3
+			}
+			p.add(&HTMLNode{Type: HTMLCommentNode, Data: p.src[start:p.pos]},
+				start)
+		case next == '!' || next == '?':
+			p.flushText()
+			end := strings.IndexByte(p.src[p.pos:], '>')
+			if end == -1 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += end + 1
+			}
+			p.add(&HTMLNode{Type: HTMLDoctypeNode, Data: p.src[start:p.pos]},
+				start)
+		case next == '/' && p.pos+2 < len(p.src) && isASCIILetter(p.src[p.pos+2]):
+			p.flushText()
+			p.endTag()
+		case isASCIILetter(next):
+			p.flushText()
+			p.startTag()
+		default:
+			// A "<" that doesn't start any markup is just text
+			if p.text == -1 {
+				p.text = p.pos
+			}
+			p.pos++
+		}
+	}
+	p.flushText()
+}
+
+// Adds any pending text as a text node.
+func (p *htmlParser) flushText() {
+	if p.text == -1 {
+		return
+	}
+	p.add(&HTMLNode{Type: HTMLTextNode, Data: p.src[p.text:p.pos]}, p.text)
+	p.text = -1
+}
+
+// Appends n, which starts at offset, to the element that's currently open.
+func (p *htmlParser) add(n *HTMLNode, offset int) {
+	n.Pos = p.lines.Pos(offset)
+	p.stack[len(p.stack)-1].AppendChild(n)
+}
+
+func (p *htmlParser) startTag() {
+	var start int = p.pos
+	p.pos++
+	var name string = p.readUntil(" \t\r\n\f/>")
+	var el = &HTMLNode{Type: HTMLElementNode, Name: name}
+
+	// Attributes, up until the closing ">"
+	for p.pos < len(p.src) {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		if p.src[p.pos] == '>' {
+			p.pos++
+			break
+		}
+		if strings.HasPrefix(p.src[p.pos:], "/>") {
+			el.SelfClosing = true
+			p.pos += 2
+			break
+		}
+		if p.src[p.pos] == '/' {
+			p.pos++
+			continue
+		}
+
+		var attr = &HTMLAttr{Pos: p.lines.Pos(p.pos)}
+		// The first character of a name may be "=", per the spec
+		p.pos++
+		attr.Name = p.src[p.pos-1:p.pos] + p.readUntil(" \t\r\n\f/>=")
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '=' {
+			attr.HasValue = true
+			p.pos++
+			p.skipSpace()
+			if p.pos < len(p.src) &&
+				(p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+				attr.Quote = p.src[p.pos]
+				p.pos++
+				attr.ValuePos = p.lines.Pos(p.pos)
+				attr.Raw = p.readUntil(string(attr.Quote))
+				if p.pos < len(p.src) {
+					p.pos++
+				}
+			} else {
+				attr.ValuePos = p.lines.Pos(p.pos)
+				attr.Raw = p.readUntil(" \t\r\n\f>")
+			}
+			attr.Value = html.UnescapeString(attr.Raw)
+		}
+		el.Attrs = append(el.Attrs, attr)
+	}
+
+	// Close any elements that this one implicitly ends, e.g. an open <li>
+	// when another <li> starts.
+	for len(p.stack) > 1 &&
+		impliesEndTag(p.stack[len(p.stack)-1].Name, name) {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+	p.add(el, start)
+
+	var lower string = strings.ToLower(name)
+	if el.SelfClosing || containsString(voidElements, lower) {
+		return
+	}
+	if containsString(rawTextElements, lower) {
+		// Everything up to the matching end tag is the element's text
+		end := indexFold(p.src[p.pos:], "</"+lower)
+		if end == -1 {
+			end = len(p.src) - p.pos
+		}
+		if end > 0 {
+			el.AppendChild(&HTMLNode{Type: HTMLTextNode,
+				Data: p.src[p.pos : p.pos+end], Pos: p.lines.Pos(p.pos)})
+		}
+		p.pos += end
+		if p.pos < len(p.src) {
+			p.readUntil(">")
+			if p.pos < len(p.src) {
+				p.pos++
+			}
+			el.EndTag = true
+		}
+		return
+	}
+	p.stack = append(p.stack, el)
+}
+
+func (p *htmlParser) endTag() {
+	var start int = p.pos
+	p.pos += 2
+	var name string = p.readUntil(" \t\r\n\f/>")
+	p.readUntil(">")
+	if p.pos < len(p.src) {
+		p.pos++
+	}
+
+	// Close the nearest open element of the same name, along with anything
+	// left open within it.
+	for i := len(p.stack) - 1; i > 0; i-- {
+		if strings.EqualFold(p.stack[i].Name, name) {
+			p.stack[i].EndTag = true
+			p.stack = p.stack[:i]
+			return
+		}
+	}
+	// End tags that don't close anything are kept as text
+	p.add(&HTMLNode{Type: HTMLTextNode, Data: p.src[start:p.pos]}, start)
+}
+
+// Advances past every character until one of chars (or the end of the
+// source) is found, returning everything that was passed over.
+func (p *htmlParser) readUntil(chars string) string {
+	var start int = p.pos
+	for p.pos < len(p.src) && strings.IndexByte(chars, p.src[p.pos]) == -1 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *htmlParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n\f", p.src[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+// Returns whether starting a <next> element closes an open <open> element.
+func impliesEndTag(open string, next string) bool {
+	open, next = strings.ToLower(open), strings.ToLower(next)
+	switch open {
+	case "p":
+		return containsString(blockElements, next)
+	case "li":
+		return next == "li"
+	case "dt", "dd":
+		return next == "dt" || next == "dd"
+	case "option":
+		return next == "option" || next == "optgroup"
+	case "td", "th":
+		return next == "td" || next == "th" || next == "tr"
+	case "tr":
+		return next == "tr"
+	}
+	return false
+}
+
+// Renders nodes back into HTML.
+func RenderHTML(nodes ...*HTMLNode) string {
+	var out strings.Builder
+	for _, n := range nodes {
+		renderHTMLNode(&out, n)
+	}
+	return out.String()
+}
+
+func renderHTMLNode(out *strings.Builder, n *HTMLNode) {
+	switch n.Type {
+	case HTMLDocumentNode:
+		for _, child := range n.Children {
+			renderHTMLNode(out, child)
+		}
+	case HTMLTextNode, HTMLCommentNode, HTMLDoctypeNode:
+		out.WriteString(n.Data)
+	case HTMLElementNode:
+		out.WriteString("<" + n.Name)
+		for _, attr := range n.Attrs {
+			out.WriteString(" " + attr.Name)
+			if !attr.HasValue {
+				continue
+			}
+			var quote string = string(attr.Quote)
+			if attr.Quote == 0 && (attr.Raw == "" ||
+				strings.ContainsAny(attr.Raw, " \t\r\n\f\"'=<>`")) {
+				quote = "\""
+			} else if attr.Quote == 0 {
+				quote = ""
+			}
+			out.WriteString("=" + quote + attr.Raw + quote)
+		}
+		if n.SelfClosing {
+			out.WriteString(" />")
+			return
+		}
+		out.WriteString(">")
+		for _, child := range n.Children {
+			renderHTMLNode(out, child)
+		}
+		if n.EndTag {
+			out.WriteString("</" + n.Name + ">")
+		}
+	}
+}
+
+// Returns the decoded text of a text node, or of all the text within an
+// element.
+func (n *HTMLNode) Text() string {
+	if n.Type == HTMLTextNode {
+		if n.Parent != nil && n.Parent.IsRawText() {
+			return n.Data
+		}
+		return html.UnescapeString(n.Data)
+	}
+	var out strings.Builder
+	for _, child := range n.Children {
+		if child.Type == HTMLTextNode || child.Type == HTMLElementNode {
+			out.WriteString(child.Text())
+		}
+	}
+	return out.String()
+}
+
+// Returns whether n is a <script> or <style> element, whose content is
+// neither parsed nor decoded.
+func (n *HTMLNode) IsRawText() bool {
+	return n.Type == HTMLElementNode &&
+		(strings.EqualFold(n.Name, "script") || strings.EqualFold(n.Name, "style"))
+}
+
+// Returns whether n is an element called name, ignoring case.
+func (n *HTMLNode) Is(name string) bool {
+	return n.Type == HTMLElementNode && strings.EqualFold(n.Name, name)
+}
+
+// Returns the attribute of n called name, ignoring case.
+func (n *HTMLNode) Attr(name string) (*HTMLAttr, bool) {
+	for _, attr := range n.Attrs {
+		if strings.EqualFold(attr.Name, name) {
+			return attr, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the (decoded) value of the attribute of n called name, or an empty
+// string if n has no such attribute.
+func (n *HTMLNode) AttrValue(name string) string {
+	if attr, ok := n.Attr(name); ok {
+		return attr.Value
+	}
+	return ""
+}
+
+// Sets the attribute of n called name to value, adding it if n doesn't have
+// it yet.
+func (n *HTMLNode) SetAttr(name string, value string) {
+	attr, ok := n.Attr(name)
+	if !ok {
+		attr = &HTMLAttr{Name: name, Quote: '"'}
+		n.Attrs = append(n.Attrs, attr)
+	}
+	attr.SetValue(value)
+}
+
+// Removes the attribute of n called name, if it has one.
+func (n *HTMLNode) RemoveAttr(name string) {
+	for i, attr := range n.Attrs {
+		if strings.EqualFold(attr.Name, name) {
+			n.Attrs = append(n.Attrs[:i], n.Attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Sets the value of attr, escaping it as needed.
+func (attr *HTMLAttr) SetValue(value string) {
+	if attr.Quote == 0 {
+		attr.Quote = '"'
+	}
+	attr.Value = value
+	attr.Raw = strings.Replace(value, "&", "&amp;", -1)
+	if attr.Quote == '"' {
+		attr.Raw = strings.Replace(attr.Raw, "\"", "&quot;", -1)
+	} else {
+		attr.Raw = strings.Replace(attr.Raw, "'", "&#39;", -1)
+	}
+	attr.HasValue = true
+}
+
+// Returns the class names of n's class attribute.
+func (n *HTMLNode) Classes() []string {
+	return strings.Fields(n.AttrValue("class"))
+}
+
+// Adds child as the last child of n.
+func (n *HTMLNode) AppendChild(child *HTMLNode) {
+	child.Parent = n
+	n.Children = append(n.Children, child)
+}
+
+// Replaces n within its parent with nodes.
+func (n *HTMLNode) ReplaceWith(nodes ...*HTMLNode) {
+	var parent *HTMLNode = n.Parent
+	if parent == nil {
+		return
+	}
+	for i, child := range parent.Children {
+		if child != n {
+			continue
+		}
+		var children []*HTMLNode
+		children = append(children, parent.Children[:i]...)
+		children = append(children, nodes...)
+		children = append(children, parent.Children[i+1:]...)
+		parent.Children = children
+		for _, node := range nodes {
+			node.Parent = parent
+		}
+		n.Parent = nil
+		return
+	}
+}
+
+// Calls fn for n and every node below it, in document order. Returning false
+// from fn skips the children of that node.
+func (n *HTMLNode) Walk(fn func(*HTMLNode) bool) {
+	if !fn(n) {
+		return
+	}
+	// Copied, so that fn may replace the nodes it's given
+	var children = append([]*HTMLNode{}, n.Children...)
+	for _, child := range children {
+		child.Walk(fn)
+	}
+}
+
+// Returns the first element below n called name, if there is one.
+func (n *HTMLNode) Find(name string) (*HTMLNode, bool) {
+	var found *HTMLNode
+	n.Walk(func(node *HTMLNode) bool {
+		if found == nil && node != n && node.Is(name) {
+			found = node
+		}
+		return found == nil
+	})
+	return found, found != nil
+}
+
+// Returns the index of the first match of substr in s, ignoring the case of
+// ASCII letters.
+func indexFold(s string, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
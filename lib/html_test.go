@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+// Returns an outline of the tree under n, such as `ul(li(text) li(text))`,
+// leaving out whitespace-only text.
+func htmlOutline(n *HTMLNode) string {
+	var parts []string
+	for _, child := range n.Children {
+		switch child.Type {
+		case HTMLElementNode:
+			var part string = child.Name
+			if len(child.Children) > 0 {
+				part += "(" + htmlOutline(child) + ")"
+			}
+			parts = append(parts, part)
+		case HTMLTextNode:
+			if strings.TrimSpace(child.Data) != "" {
+				parts = append(parts, "text")
+			}
+		case HTMLCommentNode:
+			parts = append(parts, "comment")
+		case HTMLDoctypeNode:
+			parts = append(parts, "doctype")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseHTML(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want string
+	}{
+		{"<div><p>a</p></div>", "div(p(text))"},
+		{"<ul><li>a<li>b</ul>", "ul(li(text) li(text))"},
+		{"<p>a<div>b</div>", "p(text) div(text)"},
+		{"<img src=a.png><br>text", "img br text"},
+		{"<HelloWorld /><p>a</p>", "HelloWorld p(text)"},
+		{"<div><Card />b</div>", "div(Card text)"},
+		{"<table><tr><td>a<td>b<tr><td>c</table>",
+			"table(tr(td(text) td(text)) tr(td(text)))"},
+		{"<script>if (a < b) { x('</p>') }</script>", "script(text)"},
+		{"<!DOCTYPE html><!-- hi --><html></html>", "doctype comment html"},
+		{"a < b and <3", "text"},
+		{"<div><span>open</div>after", "div(span(text)) text"},
+		{"</p>stray", "text text"}, // kept as text, so that it renders as-is
+	}
+	for _, test := range tests {
+		if got := htmlOutline(ParseHTML(test.src)); got != test.want {
+			t.Errorf("ParseHTML(%q) = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestRenderHTMLRoundTrip(t *testing.T) {
+	var tests = []string{
+		"<!DOCTYPE html>\n<html><head><title>a &amp; b</title></head></html>",
+		"<div class='a b' id=\"c\" hidden data-x=1>text</div>",
+		"<ul><li>a<li>b</ul>",
+		"<HelloWorld title=\"Hi\" />",
+		"<p>a < b</p><!-- <p> -->",
+		"<script>const s = '<div>'</script>",
+		"<div><span>unclosed</div>",
+		"<a href=\"#endnotes\">notes</a>",
+	}
+	for _, src := range tests {
+		if got := RenderHTML(ParseHTML(src)); got != src {
+			t.Errorf("RenderHTML(ParseHTML(%q)) = %q", src, got)
+		}
+	}
+}
+
+func TestHTMLAttrs(t *testing.T) {
+	var doc *HTMLNode = ParseHTML("<a href=\"?a=1&amp;b=2\" class=' x  y '" +
+		" data-on>\n<b title=it&#39;s>")
+	a, ok := doc.Find("a")
+	if !ok {
+		t.Fatal("no <a>")
+	}
+	if got := a.AttrValue("href"); got != "?a=1&b=2" {
+		t.Errorf("href = %q, want %q", got, "?a=1&b=2")
+	}
+	if attr, _ := a.Attr("href"); attr.Raw != "?a=1&amp;b=2" {
+		t.Errorf("href's Raw = %q", attr.Raw)
+	}
+	if got := strings.Join(a.Classes(), ","); got != "x,y" {
+		t.Errorf("Classes() = %q, want %q", got, "x,y")
+	}
+	if attr, ok := a.Attr("data-on"); !ok || attr.HasValue {
+		t.Errorf("data-on = %v, %v, want an attribute without a value", attr,
+			ok)
+	}
+	b, _ := doc.Find("b")
+	if b == nil || b.AttrValue("title") != "it's" {
+		t.Errorf("title of <b> isn't \"it's\"")
+	}
+	if b != nil && (b.Pos.Line != 2 || b.Pos.Col != 1) {
+		t.Errorf("<b> is at %d:%d, want 2:1", b.Pos.Line, b.Pos.Col)
+	}
+}
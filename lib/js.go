@@ -0,0 +1,846 @@
+package lib
+
+import "strings"
+
+// The types of tokens produced by LexJS().
+type JSTokenType int
+
+const (
+	JSIdent    JSTokenType = iota // identifiers and keywords
+	JSPunct                       // operators and punctuation
+	JSNumber                      // 42, 0x2a, 4.2e1, ...
+	JSString                      // '...' and "..."
+	JSTemplate                    // `...`, or its parts around ${...}
+	JSRegExp                      // /.../flags
+	JSComment                     // // ... and /* ... */
+)
+
+// A single token of JavaScript source. Value is the token's source text and
+// Pos is where it starts within the source.
+type JSToken struct {
+	Type    JSTokenType
+	Value   string
+	Pos     Pos
+	Newline bool // whether a line break comes before the token
+}
+
+// A name declared by a script.
+//
+// For JSTopLevelDeclarations(), Kind is one of "function", "class", "const",
+// "let" or "var". For JSFunctions(), it's one of "function" (declarations),
+// "function-expression" (`const f = function () {}`), "arrow"
+// (`const f = () => {}`) or "method" (class methods and arrow function
+// fields), with Class holding the name of a method's class.
+type JSDeclaration struct {
+	Name     string
+	Kind     string
+	Class    string
+	TopLevel bool
+	Pos      Pos
+}
+
+// An identifier that a script refers to.
+type JSReference struct {
+	Name     string
+	Pos      Pos
+	Property bool // accessed as a property, as in `obj.name`
+	Called   bool // called, as in `name()`
+	Listener bool // registered as an event listener (see JSReferences)
+}
+
+// Punctuators made up of more than one character, longest first.
+var jsPuncts = []string{">>>=", "...", "===", "!==", "**=", "<<=", ">>=",
+	">>>", "&&=", "||=", "??=", "=>", "==", "!=", "<=", ">=", "&&", "||",
+	"??", "?.", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"**", "<<", ">>"}
+
+// Keywords after which a "/" starts a regular expression rather than being a
+// division.
+var jsRegExpKeywords = []string{"return", "typeof", "case", "do", "else",
+	"in", "of", "new", "delete", "void", "throw", "instanceof", "yield",
+	"await"}
+
+// Keywords that start a new statement.
+var jsStatementKeywords = []string{"function", "class", "const", "let", "var",
+	"if", "for", "while", "do", "return", "switch", "try", "throw", "import",
+	"export", "async"}
+
+// Splits src into tokens. Whitespace is skipped, while comments are kept as
+// tokens so that callers can decide what to do with them.
+//
+// Template literals are split up around their ${...} expressions, so that
+// `a${b}c` becomes the JSTemplate tokens "`a${" and "}c`" with the tokens of
+// the expression b in between.
+func LexJS(src string) []JSToken {
+	tokens, _ := lexJS(src, 0, NewLineIndex(src), nil, false)
+	return tokens
+}
+
+// Appends the tokens of src, starting at src[i], to tokens. Within the
+// expression of a template literal (inTemplate), lexing stops at the "}" that
+// ends the expression, whose index is returned along with the tokens.
+// Otherwise it goes on until the end of src.
+func lexJS(src string, i int, lines *LineIndex, tokens []JSToken,
+	inTemplate bool) ([]JSToken, int) {
+	var newline bool = false
+	var depth int = 0
+
+	for i < len(src) {
+		c := src[i]
+		// Line breaks within a template literal don't end any statement
+		if c == '\n' {
+			newline = !inTemplate
+			i++
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v' {
+			i++
+			continue
+		}
+		if inTemplate && c == '}' && depth == 0 {
+			return tokens, i
+		}
+
+		var t = JSToken{Pos: lines.Pos(i), Newline: newline}
+		var end int
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			t.Type = JSComment
+			end = strings.IndexByte(src[i:], '\n')
+			if end == -1 {
+				end = len(src)
+			} else {
+				end += i
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			t.Type = JSComment
+			end = strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+		case c == '"' || c == '\'':
+			t.Type = JSString
+			end = skipJSString(src, i)
+		case c == '`':
+			tokens, i = lexJSTemplate(src, i, lines, tokens, newline)
+			newline = false
+			continue
+		case c == '/' && regExpAllowed(tokens):
+			t.Type = JSRegExp
+			end = skipJSRegExp(src, i)
+		case isJSIdentStart(c):
+			t.Type = JSIdent
+			end = i + 1
+			for end < len(src) && isJSIdentPart(src[end]) {
+				end++
+			}
+		case c >= '0' && c <= '9' ||
+			c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			t.Type = JSNumber
+			end = i + 1
+			for end < len(src) && (isJSIdentPart(src[end]) || src[end] == '.' ||
+				(src[end] == '+' || src[end] == '-') &&
+					(src[end-1] == 'e' || src[end-1] == 'E')) {
+				end++
+			}
+		default:
+			t.Type = JSPunct
+			end = i + 1
+			for _, punct := range jsPuncts {
+				if strings.HasPrefix(src[i:], punct) {
+					end = i + len(punct)
+					break
+				}
+			}
+			if c == '{' {
+				depth++
+			} else if c == '}' {
+				depth--
+			}
+		}
+
+		t.Value = src[i:end]
+		if t.Type != JSComment {
+			newline = false
+		} else if strings.Contains(t.Value, "\n") && !inTemplate {
+			newline = true
+		}
+		tokens = append(tokens, t)
+		i = end
+	}
+	return tokens, len(src)
+}
+
+// Appends the tokens of the template literal starting at src[i] to tokens:
+// its text, split up around ${...} expressions, and the tokens of those
+// expressions (which may hold template literals themselves). Returns the
+// index right after the template literal.
+func lexJSTemplate(src string, i int, lines *LineIndex, tokens []JSToken,
+	newline bool) ([]JSToken, int) {
+	var start int = i
+	for j := i + 1; j < len(src); j++ {
+		switch {
+		case src[j] == '\\':
+			j++
+		case src[j] == '`':
+			return append(tokens, JSToken{Type: JSTemplate,
+				Value: src[start : j+1], Pos: lines.Pos(start),
+				Newline: newline}), j + 1
+		case src[j] == '$' && j+1 < len(src) && src[j+1] == '{':
+			tokens = append(tokens, JSToken{Type: JSTemplate,
+				Value: src[start : j+2], Pos: lines.Pos(start),
+				Newline: newline})
+			newline = false
+			// The expression ends at its "}", where the text goes on
+			tokens, start = lexJS(src, j+2, lines, tokens, true)
+			j = start
+		}
+	}
+	if start < len(src) {
+		tokens = append(tokens, JSToken{Type: JSTemplate, Value: src[start:],
+			Pos: lines.Pos(start), Newline: newline})
+	}
+	return tokens, len(src)
+}
+
+// Returns the names declared at the top level of src: function and class
+// declarations along with const, let, and var bindings.
+func JSTopLevelDeclarations(src string) []JSDeclaration {
+	var decls []JSDeclaration
+	var tokens []JSToken = significantTokens(LexJS(src))
+	var depth int = 0
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Type == JSPunct {
+			switch t.Value {
+			case "{", "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 || t.Type != JSIdent {
+			continue
+		}
+		// Skip over property names such as `obj.function`
+		if i > 0 && (tokens[i-1].Value == "." || tokens[i-1].Value == "?.") {
+			continue
+		}
+
+		switch t.Value {
+		case "function", "class":
+			var j int = i + 1
+			if j < len(tokens) && tokens[j].Value == "*" {
+				j++
+			}
+			if j < len(tokens) && tokens[j].Type == JSIdent {
+				decls = append(decls, JSDeclaration{Name: tokens[j].Value,
+					Kind: t.Value, TopLevel: true, Pos: tokens[j].Pos})
+			}
+		case "const", "let", "var":
+			// Every declarator of the statement: `let a = 1, b = 2;`
+			var expectName bool = true
+			var nested int = 0
+			var j int
+			for j = i + 1; j < len(tokens); j++ {
+				n := tokens[j]
+				if nested == 0 && (n.Value == ";" ||
+					j > i+1 && n.Type == JSIdent && n.Newline &&
+						containsString(jsStatementKeywords, n.Value)) {
+					break
+				}
+				if expectName && (n.Type == JSIdent || n.Value == "{" ||
+					n.Value == "[") {
+					names, next := jsBindingNames(tokens, j)
+					for _, name := range names {
+						decls = append(decls, JSDeclaration{Name: name.Value,
+							Kind: t.Value, TopLevel: true, Pos: name.Pos})
+					}
+					expectName = false
+					j = next - 1
+					continue
+				}
+				switch n.Value {
+				case "{", "(", "[":
+					nested++
+				case "}", ")", "]":
+					nested--
+				case ",":
+					expectName = nested == 0
+				}
+				if nested < 0 {
+					break
+				}
+			}
+			i = j - 1
+		}
+	}
+	return decls
+}
+
+// Returns the names that the binding starting at tokens[i] declares, along
+// with the index right after it. Besides plain names, that's every name
+// within an object or array pattern, as in `{ a, b: c, d = 1, ...e }` (which
+// declares a, c, d and e) or `[f, , [g], ...h]`.
+func jsBindingNames(tokens []JSToken, i int) ([]JSToken, int) {
+	if i >= len(tokens) {
+		return nil, i
+	}
+	var open string = tokens[i].Value
+	if tokens[i].Type == JSIdent {
+		return []JSToken{tokens[i]}, i + 1
+	}
+	if open != "{" && open != "[" {
+		return nil, i
+	}
+
+	var names []JSToken
+	var j int = i + 1
+	for j < len(tokens) && tokens[j].Value != "}" && tokens[j].Value != "]" {
+		var n JSToken = tokens[j]
+		switch {
+		case n.Value == ",":
+			// A hole, as in `[, b]`
+			j++
+			continue
+		case n.Value == "...":
+			j++
+		case open == "{" && n.Value == "[":
+			// A computed key, which has to be followed by a binding
+			j = jsMatchingToken(tokens, j)
+			if j == -1 {
+				return names, len(tokens)
+			}
+			j += 2
+		case open == "{" && j+1 < len(tokens) && tokens[j+1].Value == ":":
+			// A renamed property, as in `{ a: b }`
+			j += 2
+		}
+		more, next := jsBindingNames(tokens, j)
+		names = append(names, more...)
+		if next == j {
+			next++ // not a binding at all, which shouldn't happen
+		}
+		// Skip over any default value, up to the next element
+		for j = next; j < len(tokens); j++ {
+			var v string = tokens[j].Value
+			if v == "," || v == "}" || v == "]" {
+				break
+			}
+			if v == "{" || v == "(" || v == "[" {
+				if j = jsMatchingToken(tokens, j); j == -1 {
+					return names, len(tokens)
+				}
+			}
+		}
+		if j < len(tokens) && tokens[j].Value == "," {
+			j++
+		}
+	}
+	return names, j + 1
+}
+
+// Returns every function that src defines, at any depth: function
+// declarations, functions and arrow functions assigned to const, let, or var,
+// and the methods of classes (see JSDeclaration).
+func JSFunctions(src string) []JSDeclaration {
+	var decls []JSDeclaration
+	var tokens []JSToken = significantTokens(LexJS(src))
+	var depth int = 0
+
+	// The class bodies that are currently open, as the depth inside of them
+	type classBody struct {
+		depth int
+		name  string
+	}
+	var classes []classBody
+	var pendingClass *classBody // a class whose body hasn't started yet
+
+	for i, t := range tokens {
+		if t.Type == JSPunct {
+			switch t.Value {
+			case "{":
+				depth++
+				if pendingClass != nil {
+					pendingClass.depth = depth
+					classes = append(classes, *pendingClass)
+					pendingClass = nil
+				}
+			case "}":
+				if len(classes) > 0 && classes[len(classes)-1].depth == depth {
+					classes = classes[:len(classes)-1]
+				}
+				depth--
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			}
+			continue
+		}
+		if t.Type != JSIdent || i > 0 && isJSPropertyAccess(tokens[i-1]) {
+			continue
+		}
+
+		// Class members sit right inside of the class body
+		if len(classes) > 0 && classes[len(classes)-1].depth == depth {
+			var next string
+			if i+1 < len(tokens) {
+				next = tokens[i+1].Value
+			}
+			if containsString(jsMethodModifiers, t.Value) && next != "(" ||
+				i > 0 && !isJSMemberStart(tokens[i-1], t) {
+				continue
+			}
+			var isMethod bool = next == "(" ||
+				next == "=" && jsFunctionAt(tokens, i+2) != ""
+			if isMethod && t.Value != "constructor" {
+				decls = append(decls, JSDeclaration{Name: t.Value,
+					Kind: "method", Class: classes[len(classes)-1].name,
+					Pos: t.Pos})
+			}
+			continue
+		}
+
+		switch t.Value {
+		case "class":
+			pendingClass = &classBody{}
+			if i+1 < len(tokens) && tokens[i+1].Type == JSIdent &&
+				tokens[i+1].Value != "extends" {
+				pendingClass.name = tokens[i+1].Value
+			}
+		case "function":
+			// Only declarations, not function expressions such as
+			// `setTimeout(function tick() {})`
+			var first, prev int = i, i - 1
+			if prev >= 0 && tokens[prev].Value == "async" {
+				first, prev = prev, prev-1
+			}
+			if prev >= 0 && !tokens[first].Newline &&
+				!isJSStatementStart(tokens[prev]) {
+				continue
+			}
+			var j int = i + 1
+			if j < len(tokens) && tokens[j].Value == "*" {
+				j++
+			}
+			if j < len(tokens) && tokens[j].Type == JSIdent {
+				decls = append(decls, JSDeclaration{Name: tokens[j].Value,
+					Kind: "function", TopLevel: depth == 0, Pos: tokens[j].Pos})
+			}
+		case "const", "let", "var":
+			if i+3 < len(tokens) && tokens[i+1].Type == JSIdent &&
+				tokens[i+2].Value == "=" {
+				if kind := jsFunctionAt(tokens, i+3); kind != "" {
+					decls = append(decls, JSDeclaration{Name: tokens[i+1].Value,
+						Kind: kind, TopLevel: depth == 0, Pos: tokens[i+1].Pos})
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// Returns src without the top-level functions called one of names, be they
+// function declarations or functions assigned to const, let, or var. Exported
+// functions, and declarations that declare more than one name, are kept.
+func JSRemoveFunctions(src string, names []string) string {
+	var tokens []JSToken = significantTokens(LexJS(src))
+	var depth int = 0
+	type span struct{ start, end int }
+	var removed []span
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Type == JSPunct {
+			switch t.Value {
+			case "{", "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+		if depth != 0 || t.Type != JSIdent {
+			continue
+		}
+
+		var first, prev int = i, i - 1
+		if t.Value == "function" && prev >= 0 && tokens[prev].Value == "async" {
+			first, prev = prev, prev-1
+		}
+		if prev >= 0 && (tokens[prev].Value == "export" ||
+			tokens[prev].Value == "default" || !tokens[first].Newline &&
+			!isJSStatementStart(tokens[prev])) {
+			continue
+		}
+
+		var end int = -1
+		switch t.Value {
+		case "function":
+			var j int = i + 1
+			if j < len(tokens) && tokens[j].Value == "*" {
+				j++
+			}
+			if j+1 >= len(tokens) || !containsString(names, tokens[j].Value) ||
+				tokens[j+1].Value != "(" {
+				continue
+			}
+			// The parameters, followed by the body
+			j = jsMatchingToken(tokens, j+1)
+			if j+1 < len(tokens) && tokens[j+1].Value == "{" {
+				end = jsMatchingToken(tokens, j+1)
+			}
+		case "const", "let", "var":
+			if i+3 >= len(tokens) || !containsString(names, tokens[i+1].Value) ||
+				tokens[i+2].Value != "=" || jsFunctionAt(tokens, i+3) == "" {
+				continue
+			}
+			end = jsStatementEnd(tokens, i+3)
+		default:
+			continue
+		}
+		if end == -1 || end >= len(tokens) {
+			continue
+		}
+		removed = append(removed, span{tokens[first].Pos.Offset,
+			tokens[end].Pos.Offset + len(tokens[end].Value)})
+		i = end
+	}
+
+	// Working backwards keeps the offsets of the earlier spans valid
+	for k := len(removed) - 1; k >= 0; k-- {
+		var start, end int = removed[k].start, removed[k].end
+		// Along with the rest of the line, if nothing else is on it
+		var rest int = end
+		for rest < len(src) && (src[rest] == ' ' || src[rest] == '\t') {
+			rest++
+		}
+		if rest < len(src) && src[rest] == '\n' {
+			end = rest + 1
+		} else if rest < len(src) && src[rest] == '\r' &&
+			rest+1 < len(src) && src[rest+1] == '\n' {
+			end = rest + 2
+		}
+		src = src[:start] + src[end:]
+	}
+	return src
+}
+
+// Returns the index of the token that closes the bracket at tokens[i], or -1
+// if it's never closed.
+func jsMatchingToken(tokens []JSToken, i int) int {
+	var depth int = 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].Value {
+		case "{", "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// Returns the index of the last token of the statement whose expression
+// starts at tokens[i]: either its ";" or the token before a line break that
+// ends the statement. Returns -1 for statements that go on to declare more
+// names (`const a = () => {}, b = 1;`).
+func jsStatementEnd(tokens []JSToken, i int) int {
+	var depth int = 0
+	for j := i; j < len(tokens); j++ {
+		t := tokens[j]
+		// A line break ends the statement, unless the line before ended in
+		// an operator or the next line starts with one
+		var prev JSToken
+		if j > i {
+			prev = tokens[j-1]
+		}
+		if depth == 0 && j > i && t.Newline &&
+			(t.Type != JSPunct || t.Value == "{") &&
+			(prev.Type != JSPunct || prev.Value == ")" || prev.Value == "]" ||
+				prev.Value == "}") {
+			return j - 1
+		}
+		switch t.Value {
+		case "{", "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+		case ";":
+			if depth == 0 {
+				return j
+			}
+		case ",":
+			if depth == 0 {
+				return -1
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// Returns every identifier that src refers to, leaving out keywords and the
+// names being declared by src. Identifiers passed as the listener of an
+// addEventListener() call, or assigned to an `on...` property (as in
+// `button.onclick = greet`), are marked as Listener.
+func JSReferences(src string) []JSReference {
+	var refs []JSReference
+	var tokens []JSToken = significantTokens(LexJS(src))
+
+	// The names of declarations aren't references to them
+	var declared = make(map[int]bool)
+	for _, decl := range JSFunctions(src) {
+		declared[decl.Pos.Offset] = true
+	}
+	for _, decl := range JSTopLevelDeclarations(src) {
+		declared[decl.Pos.Offset] = true
+	}
+	var listeners = jsListeners(tokens)
+
+	for i, t := range tokens {
+		if t.Type != JSIdent || declared[t.Pos.Offset] ||
+			containsString(jsKeywords, t.Value) {
+			continue
+		}
+		var prev, next string
+		if i > 0 {
+			prev = tokens[i-1].Value
+		}
+		if i+1 < len(tokens) {
+			next = tokens[i+1].Value
+		}
+		// Object keys, as in `{ name: value }`, and the names of function
+		// expressions and constructors, as in `function tick() {}`
+		if next == ":" && (prev == "{" || prev == ",") ||
+			prev == "function" || prev == "class" || t.Value == "constructor" ||
+			prev == "*" && i > 1 && tokens[i-2].Value == "function" {
+			continue
+		}
+		refs = append(refs, JSReference{
+			Name:     t.Value,
+			Pos:      t.Pos,
+			Property: i > 0 && isJSPropertyAccess(tokens[i-1]),
+			Called:   next == "(" || next == "?.",
+			Listener: listeners[i],
+		})
+	}
+	return refs
+}
+
+// Returns the indexes of the tokens that are registered as event listeners,
+// either through addEventListener(type, listener) or `x.onclick = listener`.
+func jsListeners(tokens []JSToken) map[int]bool {
+	var listeners = make(map[int]bool)
+
+	for i, t := range tokens {
+		if t.Type != JSIdent || i+1 >= len(tokens) {
+			continue
+		}
+		var start int = -1
+		if t.Value == "addEventListener" && tokens[i+1].Value == "(" {
+			// The listener is the second argument
+			var depth int = 0
+			for j := i + 2; j < len(tokens) && start == -1; j++ {
+				switch tokens[j].Value {
+				case "(", "[", "{":
+					depth++
+				case ")", "]", "}":
+					depth--
+				case ",":
+					if depth == 0 {
+						start = j + 1
+					}
+				}
+				if depth < 0 {
+					break
+				}
+			}
+		} else if strings.HasPrefix(t.Value, "on") && i > 0 &&
+			isJSPropertyAccess(tokens[i-1]) && tokens[i+1].Value == "=" {
+			start = i + 2
+		}
+		if start == -1 {
+			continue
+		}
+
+		// Only plain references count, such as `greet` or `this.greet`, not
+		// functions written in place.
+		var end int = start
+		for end+2 < len(tokens) && tokens[end].Type == JSIdent &&
+			isJSPropertyAccess(tokens[end+1]) && tokens[end+2].Type == JSIdent {
+			end += 2
+		}
+		if end < len(tokens) && tokens[end].Type == JSIdent &&
+			(end+1 == len(tokens) || tokens[end+1].Value != "(" &&
+				tokens[end+1].Value != "=>") {
+			listeners[end] = true
+		}
+	}
+	return listeners
+}
+
+// Returns the kind of function that starts at tokens[i], if any:
+// "function-expression" for `function () {}`, "arrow" for `() => {}` or
+// `x => {}`, and "" for anything else.
+func jsFunctionAt(tokens []JSToken, i int) string {
+	if i < len(tokens) && tokens[i].Value == "async" {
+		i++
+	}
+	if i >= len(tokens) {
+		return ""
+	}
+	if tokens[i].Value == "function" {
+		return "function-expression"
+	}
+	if tokens[i].Type == JSIdent && i+1 < len(tokens) &&
+		tokens[i+1].Value == "=>" {
+		return "arrow"
+	}
+	if tokens[i].Value != "(" {
+		return ""
+	}
+	var depth int = 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].Value {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				if j+1 < len(tokens) && tokens[j+1].Value == "=>" {
+					return "arrow"
+				}
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// Keywords (and the likes of true and this) that are never references.
+var jsKeywords = []string{"await", "break", "case", "catch", "class", "const",
+	"continue", "debugger", "default", "delete", "do", "else", "export",
+	"extends", "false", "finally", "for", "function", "if", "import", "in",
+	"instanceof", "let", "new", "null", "return", "super", "switch", "this",
+	"throw", "true", "try", "typeof", "var", "void", "while", "with",
+	"yield", "async", "of", "static", "get", "set", "undefined"}
+
+// Words that may come before the name of a class member.
+var jsMethodModifiers = []string{"static", "async", "get", "set"}
+
+func isJSPropertyAccess(t JSToken) bool {
+	return t.Value == "." || t.Value == "?."
+}
+
+// Returns whether name, which comes right after prev within a class body,
+// is the name of a class member rather than, say, a call in a field's
+// initializer.
+func isJSMemberStart(prev JSToken, name JSToken) bool {
+	return prev.Value == "{" || prev.Value == "}" || prev.Value == ";" ||
+		prev.Value == "*" || containsString(jsMethodModifiers, prev.Value) ||
+		name.Newline
+}
+
+// Returns whether prev, the token before a `function` keyword, means that
+// the function is a declaration.
+func isJSStatementStart(prev JSToken) bool {
+	return prev.Value == ";" || prev.Value == "{" || prev.Value == "}" ||
+		prev.Value == "export" || prev.Value == "default"
+}
+
+// Returns tokens without any comments.
+func significantTokens(tokens []JSToken) []JSToken {
+	var out []JSToken
+	for _, t := range tokens {
+		if t.Type != JSComment {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Decides whether a "/" following tokens starts a regular expression, based
+// on the last significant token.
+func regExpAllowed(tokens []JSToken) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		t := tokens[i]
+		switch t.Type {
+		case JSComment:
+			continue
+		case JSIdent:
+			return containsString(jsRegExpKeywords, t.Value)
+		case JSPunct:
+			return t.Value != ")" && t.Value != "]" && t.Value != "}" &&
+				t.Value != "++" && t.Value != "--"
+		case JSTemplate:
+			// The start of an expression within a template literal
+			return strings.HasSuffix(t.Value, "${")
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func skipJSString(src string, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		if src[j] == '\\' {
+			j++
+		} else if src[j] == src[i] || src[j] == '\n' {
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+func skipJSRegExp(src string, i int) int {
+	var inClass bool = false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return j
+		case '/':
+			if !inClass {
+				// Include the flags
+				j++
+				for j < len(src) && isJSIdentPart(src[j]) {
+					j++
+				}
+				return j
+			}
+		}
+	}
+	return len(src)
+}
+
+func isJSIdentStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isJSIdentPart(c byte) bool {
+	return isJSIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func containsString(arr []string, str string) bool {
+	for _, e := range arr {
+		if e == str {
+			return true
+		}
+	}
+	return false
+}
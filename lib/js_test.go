@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns the types and values of the significant tokens of src.
+func lexValues(src string) []string {
+	var values []string
+	for _, t := range significantTokens(LexJS(src)) {
+		values = append(values, jsTokenTypeNames[t.Type]+" "+t.Value)
+	}
+	return values
+}
+
+var jsTokenTypeNames = map[JSTokenType]string{JSIdent: "ident",
+	JSPunct: "punct", JSNumber: "number", JSString: "string",
+	JSTemplate: "template", JSRegExp: "regexp", JSComment: "comment"}
+
+func TestLexJS(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want []string
+	}{
+		{"a = b / c / d", []string{"ident a", "punct =", "ident b",
+			"punct /", "ident c", "punct /", "ident d"}},
+		// A "/" after ")" divides, so the quote isn't a string
+		{"(a) / 2 / '", []string{"punct (", "ident a", "punct )", "punct /",
+			"number 2", "punct /", "string '"}},
+		{"f(/[)]/)", []string{"ident f", "punct (", "regexp /[)]/",
+			"punct )"}},
+		{"return /a\\/b[/]/g", []string{"ident return", "regexp /a\\/b[/]/g"}},
+		{"x = `plain`", []string{"ident x", "punct =", "template `plain`"}},
+		{"`a${item(1)}b`", []string{"template `a${", "ident item",
+			"punct (", "number 1", "punct )", "template }b`"}},
+		{"`${a}${b}`", []string{"template `${", "ident a", "template }${",
+			"ident b", "template }`"}},
+		{"`${ {k: v}.k }`", []string{"template `${", "punct {", "ident k",
+			"punct :", "ident v", "punct }", "punct .", "ident k",
+			"template }`"}},
+		{"`${`in ${f()}`}`", []string{"template `${", "template `in ${",
+			"ident f", "punct (", "punct )", "template }`", "template }`"}},
+		{"`${'}'}\\${x}`", []string{"template `${", "string '}'",
+			"template }\\${x}`"}},
+		{"`${/}/.test(s)}`", []string{"template `${", "regexp /}/",
+			"punct .", "ident test", "punct (", "ident s", "punct )",
+			"template }`"}},
+		{"`open ${a", []string{"template `open ${", "ident a"}},
+	}
+	for _, test := range tests {
+		if got := lexValues(test.src); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("LexJS(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestLexJSNewlines(t *testing.T) {
+	// Line breaks within a template's expression aren't statement breaks
+	var tokens []JSToken = LexJS("x = `${\na}`\ny")
+	var want = []bool{false, false, false, false, false, true}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+	}
+	for i, token := range tokens {
+		if token.Newline != want[i] {
+			t.Errorf("token %q: Newline = %v, want %v", token.Value,
+				token.Newline, want[i])
+		}
+	}
+}
+
+func TestJSReferences(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want []string // the names that are called
+	}{
+		{"function item() {}\nel.innerHTML = `<li>${item(1)}</li>`",
+			[]string{"item"}},
+		{"const s = `${`${inner()}`}`", []string{"inner"}},
+		{"const s = `item()`", nil},
+		{"greet(); obj.say()", []string{"greet", "say"}},
+		{"function f() {}", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, ref := range JSReferences(test.src) {
+			if ref.Called {
+				got = append(got, ref.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("JSReferences(%q) calls %q, want %q", test.src, got,
+				test.want)
+		}
+	}
+}
+
+func TestJSRemoveFunctions(t *testing.T) {
+	var tests = []struct {
+		src   string
+		names []string
+		want  string
+	}{
+		{"function a() {}\nfunction b() {}\n", []string{"a"},
+			"function b() {}\n"},
+		{"const a = () => `${x}`\nb()\n", []string{"a"}, "b()\n"},
+		{"const a = () => `${\nx}`\nb()\n", []string{"a"}, "b()\n"},
+		{"export function a() {}\n", []string{"a"},
+			"export function a() {}\n"},
+	}
+	for _, test := range tests {
+		if got := JSRemoveFunctions(test.src, test.names); got != test.want {
+			t.Errorf("JSRemoveFunctions(%q, %q) = %q, want %q", test.src,
+				test.names, got, test.want)
+		}
+	}
+}
+
+func TestJSTopLevelDeclarations(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want []string
+	}{
+		{"function a() {}\nclass B {}\nfunction* c() {}",
+			[]string{"a", "B", "c"}},
+		{"let a = 1, b = f(x, y), c", []string{"a", "b", "c"}},
+		{"const { a, b } = obj", []string{"a", "b"}},
+		{"const { a: b, c: { d } } = obj", []string{"b", "d"}},
+		{"const { a = 1, b: c = {x: 1}, ...rest } = obj",
+			[]string{"a", "c", "rest"}},
+		{"const { [key]: value } = obj", []string{"value"}},
+		{"let [a, , [b, c], d = [1, 2], ...e] = arr",
+			[]string{"a", "b", "c", "d", "e"}},
+		{"const [{ a }, { b: [c] }] = arr, d = 1", []string{"a", "c", "d"}},
+		{"var { close } = dialog\nfunction open() {}",
+			[]string{"close", "open"}},
+		{"if (x) { let inner = 1 }\nobj.function = 1", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, decl := range JSTopLevelDeclarations(test.src) {
+			got = append(got, decl.Name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("JSTopLevelDeclarations(%q) = %q, want %q", test.src,
+				got, test.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A problem with a JSON document, and where it was found.
+type JSONError struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *JSONError) Error() string {
+	return strconv.Itoa(e.Pos.Line) + ":" + strconv.Itoa(e.Pos.Col) + ": " +
+		e.Msg
+}
+
+// Decodes the JSON document src into v, a pointer to a struct, more strictly
+// than encoding/json does: keys that have no field, and values of the wrong
+// type, are returned as a *JSONError pointing at them. Fields are matched to
+// keys through their json tags, which must match exactly. Fields (and map
+// entries) that src leaves out keep the value they had.
+func DecodeJSONStrict(src string, v interface{}) error {
+	var d = &jsonDecoder{src: src, lines: NewLineIndex(src),
+		dec: json.NewDecoder(strings.NewReader(src))}
+	d.dec.UseNumber()
+	if err := d.value(reflect.ValueOf(v).Elem(), ""); err != nil {
+		return err
+	}
+	var end int = d.next()
+	if _, err := d.dec.Token(); err != io.EOF {
+		return d.errorf(end, "unexpected content after the end of the "+
+			"document")
+	}
+	return nil
+}
+
+type jsonDecoder struct {
+	src   string
+	lines *LineIndex
+	dec   *json.Decoder
+}
+
+// Returns the offset that the next token starts at.
+func (d *jsonDecoder) next() int {
+	var offset int = int(d.dec.InputOffset())
+	for offset < len(d.src) &&
+		strings.IndexByte(" \t\r\n,:", d.src[offset]) != -1 {
+		offset++
+	}
+	return offset
+}
+
+func (d *jsonDecoder) errorf(offset int, format string,
+	args ...interface{}) error {
+	return &JSONError{d.lines.Pos(offset), fmt.Sprintf(format, args...)}
+}
+
+// Reads the next token, turning syntax errors into a *JSONError.
+func (d *jsonDecoder) token() (json.Token, int, error) {
+	var start int = d.next()
+	tok, err := d.dec.Token()
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		return nil, start, d.errorf(int(syntaxErr.Offset), "%s", err)
+	}
+	if err == io.EOF {
+		return nil, start, d.errorf(start, "unexpected end of the document")
+	}
+	if err != nil {
+		return nil, start, d.errorf(start, "%s", err)
+	}
+	return tok, start, nil
+}
+
+// Decodes the next value into v, where path names the value in errors, e.g.
+// "site.url".
+func (d *jsonDecoder) value(v reflect.Value, path string) error {
+	tok, start, err := d.token()
+	if err != nil {
+		return err
+	}
+	mismatch := func(want string) error {
+		var name string = "the document"
+		if path != "" {
+			name = "\"" + path + "\""
+		}
+		return d.errorf(start, "%s should be %s, not %s", name, want,
+			describeJSONToken(tok))
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		if tok != json.Delim('{') {
+			return mismatch("an object")
+		}
+		if v.Kind() == reflect.Map && v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for d.dec.More() {
+			tok, keyStart, err := d.token()
+			if err != nil {
+				return err
+			}
+			var key string = tok.(string)
+			var keyPath string = key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if v.Kind() == reflect.Map {
+				var elem reflect.Value = reflect.New(v.Type().Elem()).Elem()
+				if err := d.value(elem, keyPath); err != nil {
+					return err
+				}
+				v.SetMapIndex(reflect.ValueOf(key), elem)
+				continue
+			}
+			field, ok := jsonField(v, key)
+			if !ok {
+				var where string = ""
+				if path != "" {
+					where = " in \"" + path + "\""
+				}
+				return d.errorf(keyStart, "unknown key \"%s\"%s, expected "+
+					"one of: %s", key, where,
+					strings.Join(jsonFieldNames(v.Type()), ", "))
+			}
+			if err := d.value(field, keyPath); err != nil {
+				return err
+			}
+		}
+		_, _, err := d.token() // the closing }
+		return err
+	case reflect.Slice:
+		if tok != json.Delim('[') {
+			return mismatch("an array")
+		}
+		var slice reflect.Value = reflect.MakeSlice(v.Type(), 0, 0)
+		for i := 0; d.dec.More(); i++ {
+			var elem reflect.Value = reflect.New(v.Type().Elem()).Elem()
+			if err := d.value(elem, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		v.Set(slice)
+		_, _, err := d.token() // the closing ]
+		return err
+	case reflect.String:
+		s, ok := tok.(string)
+		if !ok {
+			return mismatch("a string")
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := tok.(bool)
+		if !ok {
+			return mismatch("true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, ok := tok.(json.Number)
+		if !ok {
+			return mismatch("a number")
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return d.errorf(start, "\"%s\" should be a whole number, not %s",
+				path, n)
+		}
+		v.SetInt(i)
+	default:
+		return d.errorf(start, "\"%s\" can't be decoded into a %s", path,
+			v.Kind())
+	}
+	return nil
+}
+
+// Returns the field of the struct v whose json tag is key.
+func jsonField(v reflect.Value, key string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		if jsonFieldName(v.Type().Field(i)) == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Returns the keys that the struct type t has fields for, in order.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonFieldName(t.Field(i)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	var name string = strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" || field.PkgPath != "" {
+		return ""
+	}
+	return name
+}
+
+// Describes what tok is, e.g. "a number".
+func describeJSONToken(tok json.Token) string {
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '{' {
+			return "an object"
+		}
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
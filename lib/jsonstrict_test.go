@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testJSONConfig struct {
+	Name  string            `json:"name"`
+	Count int               `json:"count"`
+	On    bool              `json:"on"`
+	Tags  []string          `json:"tags"`
+	Rules map[string]string `json:"rules"`
+	Inner struct {
+		URL string `json:"url"`
+	} `json:"inner"`
+}
+
+func TestDecodeJSONStrict(t *testing.T) {
+	var tests = []struct {
+		src  string
+		want string // the error, or "" for none
+	}{
+		{`{"name": "a", "count": 2, "on": true, "tags": ["x"],
+			"rules": {"a": "b"}, "inner": {"url": "u"}}`, ""},
+		{`{}`, ""},
+		{`{"nmae": "a"}`, `1:2: unknown key "nmae", expected one of: ` +
+			`name, count, on, tags, rules, inner`},
+		{"{\n\t\"inner\": {\"uri\": 1}\n}", `2:12: unknown key "uri" in ` +
+			`"inner", expected one of: url`},
+		{`{"count": "2"}`, `1:11: "count" should be a number, not a string`},
+		{`{"count": 2.5}`, `1:11: "count" should be a whole number, not 2.5`},
+		{`{"on": "yes"}`, `1:8: "on" should be true or false, not a string`},
+		{`{"tags": [1]}`, `1:11: "tags[0]" should be a string, not a number`},
+		{`{"rules": []}`, `1:11: "rules" should be an object, not an array`},
+		{`[]`, `1:1: the document should be an object, not an array`},
+		{`{"name": "a"`, `1:13: unexpected end of JSON input`},
+		{`{"name": "a"} {}`, `1:15: unexpected content after the end of ` +
+			`the document`},
+	}
+	for _, test := range tests {
+		var cfg testJSONConfig
+		var got string
+		if err := DecodeJSONStrict(test.src, &cfg); err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("DecodeJSONStrict(%q) = %q, want %q", test.src, got,
+				test.want)
+		}
+	}
+}
+
+func TestDecodeJSONStrictKeepsDefaults(t *testing.T) {
+	var cfg = testJSONConfig{Name: "default", Count: 3,
+		Rules: map[string]string{"a": "b"}}
+	if err := DecodeJSONStrict(`{"count": 4, "rules": {"c": "d"}}`,
+		&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "default" || cfg.Count != 4 {
+		t.Errorf("got name %q and count %d, want \"default\" and 4", cfg.Name,
+			cfg.Count)
+	}
+	if want := map[string]string{"a": "b", "c": "d"}; !reflect.DeepEqual(
+		cfg.Rules, want) {
+		t.Errorf("rules = %v, want %v", cfg.Rules, want)
+	}
+}
@@ -0,0 +1,1216 @@
+package lib
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Converts Markdown into HTML. The block and inline syntax of CommonMark is
+// supported, along with GitHub's tables and strikethrough. Fenced code
+// blocks get a "language-*" class, and headings an id that they can be linked
+// to (`## Getting started` becomes `<h2 id="getting-started">`).
+//
+// HTML within the Markdown is passed through as-is, the way CommonMark
+// describes it: a line starting with a tag starts a block of HTML that lasts
+// until the next blank line, and tags within text stay tags. That's what lets
+// pages use components (and <webes-layout>) from Markdown.
+//
+// Also returns the 1-based line of src that each line of the HTML came from,
+// so that problems found within the HTML can be traced back to src.
+func MarkdownToHTML(src string) (string, []int) {
+	src = strings.Replace(src, "\r\n", "\n", -1)
+	var lines []mdLine
+	for i, text := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+		lines = append(lines, mdLine{expandTabs(text), i + 1})
+	}
+	// Links may refer to definitions further down, so those are collected
+	// by a first pass whose output is thrown away
+	var defs = &mdRenderer{ids: make(map[string]bool),
+		refs: make(map[string]mdLinkDef)}
+	defs.blocks(lines, false)
+	var r = &mdRenderer{ids: make(map[string]bool), refs: defs.refs,
+		atLineStart: true}
+	r.blocks(lines, false)
+	return r.out.String(), r.lines
+}
+
+// A line of Markdown, along with its 1-based line number within the source.
+type mdLine struct {
+	text string
+	num  int
+}
+
+type mdRenderer struct {
+	out         strings.Builder
+	lines       []int // the source line of each line of out
+	cur         int   // the source line that's being rendered
+	atLineStart bool
+	ids         map[string]bool // the heading ids used so far
+	// The link reference definitions, by their normalized label (see
+	// normalizeLabel())
+	refs map[string]mdLinkDef
+	// Set after the <li> of a list item, and after a paragraph within a
+	// tight list, which aren't followed by a line break of their own
+	pendingBreak bool
+}
+
+// A link reference definition, `[label]: destination "title"`.
+type mdLinkDef struct {
+	dest  string
+	title string
+}
+
+// Appends s to the output, attributing each line of it to the source line
+// num, and every line after that to the ones following num.
+func (r *mdRenderer) write(s string, num int) {
+	r.cur = num
+	for s != "" {
+		if r.atLineStart {
+			r.lines = append(r.lines, r.cur)
+			r.atLineStart = false
+		}
+		idx := strings.IndexByte(s, '\n')
+		if idx == -1 {
+			r.out.WriteString(s)
+			return
+		}
+		r.out.WriteString(s[:idx+1])
+		s = s[idx+1:]
+		r.atLineStart = true
+		r.cur++
+	}
+}
+
+// Starts a block on a line of its own.
+func (r *mdRenderer) startBlock(num int) {
+	if r.pendingBreak {
+		r.write("\n", num)
+		r.pendingBreak = false
+	}
+}
+
+var (
+	mdATXHeading = regexp.MustCompile(
+		`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	mdSetextH1 = regexp.MustCompile(`^ {0,3}=+[ \t]*$`)
+	mdSetextH2 = regexp.MustCompile(`^ {0,3}-+[ \t]*$`)
+	mdThematic = regexp.MustCompile(
+		`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdFence = regexp.MustCompile(
+		"^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*?)[ \t]*$")
+	mdBullet     = regexp.MustCompile(`^( {0,3})([-*+])([ \t]+|$)`)
+	mdOrdered    = regexp.MustCompile(`^( {0,3})([0-9]{1,9})([.)])([ \t]+|$)`)
+	mdTableDelim = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*` +
+		`(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdOpenTag = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9-]*` +
+		`(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*` +
+		`(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>`)
+	mdCloseTag    = regexp.MustCompile(`^</[A-Za-z][A-Za-z0-9-]*\s*>`)
+	mdComment     = regexp.MustCompile(`^(?s)<!--.*?-->`)
+	mdInstruction = regexp.MustCompile(`^(?s)<\?.*?\?>|^<![A-Za-z][^>]*>|` +
+		`^(?s)<!\[CDATA\[.*?\]\]>`)
+	mdAutolink  = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9.+-]{1,31}:[^\s<>]*>`)
+	mdEmailLink = regexp.MustCompile(`^<[A-Za-z0-9.!#$%&'*+/=?^_` + "`" +
+		`{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?` +
+		`(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*>`)
+	mdEntity = regexp.MustCompile(
+		`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	mdHTMLBlockTag = regexp.MustCompile(
+		`^ {0,3}</?([A-Za-z][A-Za-z0-9-]*)(?:[ \t>]|/>|$)`)
+)
+
+// The elements that start a block of HTML even in the middle of a paragraph
+// (CommonMark's HTML blocks of type 6).
+var mdBlockElements = []string{"address", "article", "aside", "base",
+	"basefont", "blockquote", "body", "caption", "center", "col", "colgroup",
+	"dd", "details", "dialog", "dir", "div", "dl", "dt", "fieldset",
+	"figcaption", "figure", "footer", "form", "frame", "frameset", "h1", "h2",
+	"h3", "h4", "h5", "h6", "head", "header", "hr", "html", "iframe",
+	"legend", "li", "link", "main", "menu", "menuitem", "nav", "noframes",
+	"ol", "optgroup", "option", "p", "param", "search", "section", "summary",
+	"table", "tbody", "td", "tfoot", "th", "thead", "title", "tr", "track",
+	"ul"}
+
+// The elements whose content is never Markdown, so that their block of HTML
+// lasts until they're closed rather than until a blank line.
+var mdRawElements = []string{"pre", "script", "style", "textarea"}
+
+// Replaces the tabs within the indentation of line with spaces, using tab
+// stops of 4.
+func expandTabs(line string) string {
+	var out strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			out.WriteByte(' ')
+		case '\t':
+			out.WriteString(strings.Repeat(" ", 4-out.Len()%4))
+		default:
+			return out.String() + line[i:]
+		}
+	}
+	return out.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// Removes up to n spaces of indentation from line.
+func dedent(line string, n int) string {
+	if indent := indentOf(line); indent < n {
+		n = indent
+	}
+	return line[n:]
+}
+
+// Returns whether line starts a block that interrupts a paragraph, rather
+// than continuing it.
+func interruptsParagraph(line string) bool {
+	if mdThematic.MatchString(line) || mdATXHeading.MatchString(line) ||
+		mdFence.MatchString(line) {
+		return true
+	}
+	if trimmed := strings.TrimLeft(line, " "); indentOf(line) < 4 &&
+		strings.HasPrefix(trimmed, ">") {
+		return true
+	}
+	if m := mdBullet.FindStringSubmatch(line); m != nil {
+		return !isBlank(line[len(m[0]):])
+	}
+	if m := mdOrdered.FindStringSubmatch(line); m != nil {
+		return m[2] == "1" && !isBlank(line[len(m[0]):])
+	}
+	kind, _ := htmlBlockStart(line)
+	return kind != "" && kind != "tag"
+}
+
+// Returns what kind of HTML block line starts, if any: "raw" for the likes
+// of <script> (which last until they're closed), "comment", "instruction",
+// "block" for block-level elements, and "tag" for any other tag that's alone
+// on its line. end is what ends the raw, comment and instruction blocks.
+func htmlBlockStart(line string) (kind string, end string) {
+	if indentOf(line) > 3 {
+		return "", ""
+	}
+	var trimmed string = strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "<") {
+		return "", ""
+	}
+	if m := mdHTMLBlockTag.FindStringSubmatch(line); m != nil {
+		var name string = strings.ToLower(m[1])
+		if !strings.HasPrefix(trimmed, "</") && containsString(mdRawElements,
+			name) {
+			return "raw", "</" + name + ">"
+		}
+		if containsString(mdBlockElements, name) {
+			return "block", ""
+		}
+	}
+	switch {
+	case strings.HasPrefix(trimmed, "<!--"):
+		return "comment", "-->"
+	case strings.HasPrefix(trimmed, "<?"):
+		return "instruction", "?>"
+	case strings.HasPrefix(trimmed, "<![CDATA["):
+		return "instruction", "]]>"
+	case strings.HasPrefix(trimmed, "<!") && len(trimmed) > 2 &&
+		isASCIILetter(trimmed[2]):
+		return "instruction", ">"
+	}
+	for _, tag := range []*regexp.Regexp{mdOpenTag, mdCloseTag} {
+		if loc := tag.FindStringIndex(trimmed); loc != nil && loc[1] ==
+			len(trimmed) {
+			return "tag", ""
+		}
+	}
+	return "", ""
+}
+
+// Renders lines as a sequence of blocks. In a tight list (one without blank
+// lines between its items), paragraphs aren't wrapped in <p>.
+func (r *mdRenderer) blocks(lines []mdLine, tight bool) {
+	for i := 0; i < len(lines); {
+		var line mdLine = lines[i]
+		switch {
+		case isBlank(line.text):
+			i++
+		case indentOf(line.text) >= 4:
+			i = r.indentedCode(lines, i)
+		case mdFence.MatchString(line.text):
+			i = r.fencedCode(lines, i)
+		case mdThematic.MatchString(line.text):
+			r.startBlock(line.num)
+			r.write("<hr />\n", line.num)
+			i++
+		case mdATXHeading.MatchString(line.text):
+			m := mdATXHeading.FindStringSubmatch(line.text)
+			r.heading(len(m[1]), m[2], line.num)
+			i++
+		case strings.HasPrefix(strings.TrimLeft(line.text, " "), ">"):
+			i = r.blockquote(lines, i)
+		case mdBullet.MatchString(line.text) || mdOrdered.MatchString(line.text):
+			i = r.list(lines, i)
+		default:
+			if kind, end := htmlBlockStart(line.text); kind != "" {
+				i = r.htmlBlock(lines, i, end)
+			} else if i+1 < len(lines) && isTableStart(line.text,
+				lines[i+1].text) {
+				i = r.table(lines, i)
+			} else {
+				i = r.paragraph(lines, i, tight)
+			}
+		}
+	}
+}
+
+func (r *mdRenderer) indentedCode(lines []mdLine, i int) int {
+	var start int = i
+	var code []string
+	var end int = i
+	for ; i < len(lines); i++ {
+		if isBlank(lines[i].text) {
+			code = append(code, dedent(lines[i].text, 4))
+			continue
+		}
+		if indentOf(lines[i].text) < 4 {
+			break
+		}
+		code = append(code, lines[i].text[4:])
+		end = i + 1
+	}
+	// Trailing blank lines aren't part of the code
+	code = code[:end-start]
+	r.startBlock(lines[start].num)
+	r.write("<pre><code>"+html.EscapeString(strings.Join(code, "\n"))+
+		"\n</code></pre>\n", lines[start].num)
+	return end
+}
+
+func (r *mdRenderer) fencedCode(lines []mdLine, i int) int {
+	m := mdFence.FindStringSubmatch(lines[i].text)
+	var indent, fence, info string = m[1], m[2], m[3]
+	var start int = i
+	var code []string
+	for i++; i < len(lines); i++ {
+		var trimmed string = strings.TrimSpace(lines[i].text)
+		if indentOf(lines[i].text) < 4 &&
+			strings.HasPrefix(trimmed, fence) &&
+			strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, dedent(lines[i].text, len(indent)))
+	}
+
+	var class string
+	if fields := strings.Fields(info); len(fields) > 0 {
+		class = ` class="language-` + html.EscapeString(unescapeMarkdown(
+			fields[0])) + `"`
+	}
+	var text string = html.EscapeString(strings.Join(code, "\n"))
+	if len(code) > 0 {
+		text += "\n"
+	}
+	r.startBlock(lines[start].num)
+	r.write("<pre><code"+class+">", lines[start].num)
+	r.write(text, lines[start].num+1)
+	r.write("</code></pre>\n", lines[start].num+len(code)+1)
+	return i
+}
+
+func (r *mdRenderer) heading(level int, text string, num int) {
+	var content string = r.renderInline(strings.TrimSpace(text))
+	var id string = r.headingID(content)
+	r.startBlock(num)
+	r.write("<h"+strconv.Itoa(level)+` id="`+id+`">`+content+
+		"</h"+strconv.Itoa(level)+">\n", num)
+}
+
+// Returns a unique id for the heading whose content (as HTML) is given, made
+// from its text in the same way that GitHub does it: lowercased, with spaces
+// replaced by hyphens, and punctuation left out. Repeated ids get a "-1",
+// "-2", ... suffix.
+func (r *mdRenderer) headingID(content string) string {
+	var text string = html.UnescapeString(stripTags(content))
+	var slug strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '-' || c == '_':
+			slug.WriteRune(c)
+		case c == ' ':
+			slug.WriteByte('-')
+		}
+	}
+	var id string = slug.String()
+	if id == "" {
+		id = "section"
+	}
+	var unique string = id
+	for n := 1; r.ids[unique]; n++ {
+		unique = id + "-" + strconv.Itoa(n)
+	}
+	r.ids[unique] = true
+	return html.EscapeString(unique)
+}
+
+// Returns the text of s, some HTML, without any of its tags.
+func stripTags(s string) string {
+	var out strings.Builder
+	var inTag bool = false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '<':
+			inTag = true
+		case s[i] == '>' && inTag:
+			inTag = false
+		case !inTag:
+			out.WriteByte(s[i])
+		}
+	}
+	return out.String()
+}
+
+func (r *mdRenderer) blockquote(lines []mdLine, i int) int {
+	var inner []mdLine
+	var lazy bool = false // whether a paragraph can be continued lazily
+	for ; i < len(lines); i++ {
+		var text string = lines[i].text
+		var trimmed string = strings.TrimLeft(text, " ")
+		if indentOf(text) < 4 && strings.HasPrefix(trimmed, ">") {
+			trimmed = trimmed[1:]
+			if strings.HasPrefix(trimmed, " ") {
+				trimmed = trimmed[1:]
+			}
+			inner = append(inner, mdLine{trimmed, lines[i].num})
+			lazy = !isBlank(trimmed) && !mdFence.MatchString(trimmed) &&
+				indentOf(trimmed) < 4
+			continue
+		}
+		if !lazy || isBlank(text) || interruptsParagraph(text) {
+			break
+		}
+		inner = append(inner, lines[i])
+	}
+	r.startBlock(inner[0].num)
+	r.write("<blockquote>\n", inner[0].num)
+	r.blocks(inner, false)
+	r.startBlock(lines[i-1].num)
+	r.write("</blockquote>\n", lines[i-1].num)
+	return i
+}
+
+// A list marker, such as "-" or "3.", at the start of a line.
+type mdListMarker struct {
+	ordered bool
+	char    byte // "-", "*" or "+" for bullets, "." or ")" for ordered lists
+	start   int  // the number of an ordered list item
+	indent  int  // where the item's content starts
+}
+
+func parseListMarker(line string) (mdListMarker, bool) {
+	if mdThematic.MatchString(line) {
+		return mdListMarker{}, false
+	}
+	var marker mdListMarker
+	var m []string
+	if m = mdBullet.FindStringSubmatch(line); m != nil {
+		marker.char = m[2][0]
+	} else if m = mdOrdered.FindStringSubmatch(line); m != nil {
+		marker.ordered = true
+		marker.start, _ = strconv.Atoi(m[2])
+		marker.char = m[3][0]
+	} else {
+		return mdListMarker{}, false
+	}
+	var space string = m[len(m)-1]
+	marker.indent = len(m[0])
+	// Content that's indented further than that is indented code within
+	// the item, and empty items start their content on the next line
+	if len(space) > 4 || isBlank(line[len(m[0]):]) {
+		marker.indent = len(m[0]) - len(space) + 1
+	}
+	return marker, true
+}
+
+func (r *mdRenderer) list(lines []mdLine, i int) int {
+	first, _ := parseListMarker(lines[i].text)
+
+	// Split the list into its items, each with its lines dedented
+	var items [][]mdLine
+	var loose bool = false
+	var blankBefore bool = false
+	for i < len(lines) {
+		marker, ok := parseListMarker(lines[i].text)
+		if !ok || marker.ordered != first.ordered ||
+			marker.char != first.char {
+			break
+		}
+		if blankBefore {
+			loose = true
+		}
+		var item = []mdLine{{"", lines[i].num}}
+		if len(lines[i].text) > marker.indent {
+			item[0].text = lines[i].text[marker.indent:]
+		}
+		blankBefore = false
+		for i++; i < len(lines); i++ {
+			var text string = lines[i].text
+			if isBlank(text) {
+				item = append(item, mdLine{"", lines[i].num})
+				continue
+			}
+			if indentOf(text) >= marker.indent {
+				item = append(item, mdLine{text[marker.indent:], lines[i].num})
+				continue
+			}
+			var last string = item[len(item)-1].text
+			if isBlank(last) || interruptsParagraph(text) {
+				break
+			}
+			if _, ok := parseListMarker(text); ok {
+				break
+			}
+			// A lazy continuation line of the item's paragraph
+			item = append(item, lines[i])
+		}
+		// Blank lines at the end of an item separate it from the next one
+		for len(item) > 1 && isBlank(item[len(item)-1].text) {
+			item = item[:len(item)-1]
+			blankBefore = true
+		}
+		for _, line := range item[1:] {
+			if isBlank(line.text) && !inFence(item, line.num) {
+				loose = true
+			}
+		}
+		items = append(items, item)
+	}
+
+	var tag string = "ul"
+	var open string = "<ul>\n"
+	if first.ordered {
+		tag = "ol"
+		open = "<ol>\n"
+		if first.start != 1 {
+			open = `<ol start="` + strconv.Itoa(first.start) + "\">\n"
+		}
+	}
+	r.startBlock(items[0][0].num)
+	r.write(open, items[0][0].num)
+	for _, item := range items {
+		r.write("<li>", item[0].num)
+		r.pendingBreak = true
+		r.blocks(item, !loose)
+		r.pendingBreak = false
+		r.write("</li>\n", item[len(item)-1].num)
+	}
+	r.write("</"+tag+">\n", items[len(items)-1][len(items[len(items)-1])-1].num)
+	return i
+}
+
+// Returns whether the line numbered num is within a fenced code block of
+// lines, whose blank lines don't make a list loose.
+func inFence(lines []mdLine, num int) bool {
+	var open string
+	for _, line := range lines {
+		if line.num == num {
+			return open != ""
+		}
+		if m := mdFence.FindStringSubmatch(line.text); m != nil {
+			if open == "" {
+				open = m[2][:1]
+			} else if strings.HasPrefix(strings.TrimSpace(line.text), open) {
+				open = ""
+			}
+		}
+	}
+	return false
+}
+
+func (r *mdRenderer) htmlBlock(lines []mdLine, i int, end string) int {
+	var start int = i
+	var text []string
+	for ; i < len(lines); i++ {
+		if end == "" && isBlank(lines[i].text) {
+			break
+		}
+		text = append(text, lines[i].text)
+		if end != "" && strings.Contains(strings.ToLower(lines[i].text), end) {
+			i++
+			break
+		}
+	}
+	r.startBlock(lines[start].num)
+	r.write(strings.Join(text, "\n")+"\n", lines[start].num)
+	return i
+}
+
+func (r *mdRenderer) paragraph(lines []mdLine, i int, tight bool) int {
+	// Link reference definitions at the start of the paragraph aren't part
+	// of it, and may be all there is to it
+	i = r.linkDefinitions(lines, i)
+	if i == len(lines) || isBlank(lines[i].text) {
+		return i
+	}
+	var start int = i
+	var text []string
+	for ; i < len(lines); i++ {
+		var line string = lines[i].text
+		if i > start {
+			// An underline turns the paragraph so far into a heading
+			if mdSetextH1.MatchString(line) || mdSetextH2.MatchString(line) {
+				var level int = 1
+				if mdSetextH2.MatchString(line) {
+					level = 2
+				}
+				r.heading(level, strings.Join(text, "\n"), lines[start].num)
+				return i + 1
+			}
+			if isBlank(line) || interruptsParagraph(line) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+
+	var content string = r.renderInline(strings.TrimRight(
+		strings.Join(text, "\n"), " \t"))
+	if tight {
+		// Right after the <li>, on the same line
+		r.pendingBreak = false
+		r.write(content, lines[start].num)
+		r.pendingBreak = true
+		return i
+	}
+	r.startBlock(lines[start].num)
+	r.write("<p>"+content+"</p>\n", lines[start].num)
+	return i
+}
+
+// Records the link reference definitions that start at lines[i], returning
+// the index of the first line after them. The first definition of a label
+// wins.
+func (r *mdRenderer) linkDefinitions(lines []mdLine, i int) int {
+	for i < len(lines) && strings.HasPrefix(strings.TrimLeft(lines[i].text,
+		" "), "[") && indentOf(lines[i].text) < 4 {
+		// A definition may go on over the lines that follow, up to the end
+		// of the paragraph
+		var text []string
+		for j := i; j < len(lines) && !isBlank(lines[j].text); j++ {
+			text = append(text, strings.TrimLeft(lines[j].text, " "))
+		}
+		var joined string = strings.Join(text, "\n")
+		label, dest, title, n := parseLinkDefinition(joined)
+		if n == 0 {
+			break
+		}
+		var key string = normalizeLabel(label)
+		if _, ok := r.refs[key]; !ok {
+			r.refs[key] = mdLinkDef{dest, title}
+		}
+		i += strings.Count(joined[:n], "\n")
+		if n == len(joined) {
+			i++ // the last line, which has no line break of its own
+		}
+	}
+	return i
+}
+
+// Parses the link reference definition at the start of text,
+// `[label]: destination "title"`, which has to end a line, returning its parts
+// along with its length (including the line break after it). n is 0 if text
+// doesn't start with a definition.
+func parseLinkDefinition(text string) (label string, dest string,
+	title string, n int) {
+	var end int = -1
+	for i := 1; i < len(text) && end == -1; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			return "", "", "", 0
+		case ']':
+			end = i
+		}
+	}
+	if end == -1 || end+1 >= len(text) || text[end+1] != ':' ||
+		strings.TrimSpace(text[1:end]) == "" {
+		return "", "", "", 0
+	}
+	label = text[1:end]
+
+	var i int = skipLinkSpace(text, end+2)
+	var start int = i
+	if i < len(text) && text[i] == '<' {
+		close := strings.IndexAny(text[i:], ">\n")
+		if close == -1 || text[i+close] != '>' {
+			return "", "", "", 0
+		}
+		dest = text[i+1 : i+close]
+		i += close + 1
+	} else {
+		for i < len(text) && text[i] > ' ' {
+			i++
+		}
+		dest = text[start:i]
+		if dest == "" {
+			return "", "", "", 0
+		}
+	}
+	var afterDest int = i
+
+	// The title is optional, and if it doesn't end its line, the definition
+	// ends with the destination instead
+	i = skipLinkSpace(text, i)
+	if i < len(text) && i > afterDest && strings.IndexByte(`"'(`,
+		text[i]) != -1 {
+		var closing byte = text[i]
+		if closing == '(' {
+			closing = ')'
+		}
+		if close := strings.IndexByte(text[i+1:], closing); close != -1 {
+			if n := endOfLine(text, i+2+close); n != -1 {
+				return label, unescapeMarkdown(dest),
+					unescapeMarkdown(text[i+1 : i+1+close]), n
+			}
+		}
+	}
+	if n := endOfLine(text, afterDest); n != -1 {
+		return label, unescapeMarkdown(dest), "", n
+	}
+	return "", "", "", 0
+}
+
+// Returns the index right after the line break that ends the line at
+// text[i], if there's nothing but spaces and tabs before it, or -1 if there
+// is.
+func endOfLine(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	if i == len(text) {
+		return i
+	}
+	if text[i] == '\n' {
+		return i + 1
+	}
+	return -1
+}
+
+// Returns label the way that link labels are matched: case-insensitively,
+// and with any run of whitespace counting as a single space.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// Returns whether header and delim, two consecutive lines, start a table:
+// a row of cells followed by a row of dashes for each of them.
+func isTableStart(header string, delim string) bool {
+	if !strings.Contains(header, "|") || !mdTableDelim.MatchString(delim) {
+		return false
+	}
+	return len(splitTableRow(header)) == len(splitTableRow(delim))
+}
+
+// Splits a table row into the text of its cells. Pipes within cells are
+// escaped as `\|`.
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func (r *mdRenderer) table(lines []mdLine, i int) int {
+	var header []string = splitTableRow(lines[i].text)
+	var aligns []string
+	for _, delim := range splitTableRow(lines[i+1].text) {
+		var align string
+		switch {
+		case strings.HasPrefix(delim, ":") && strings.HasSuffix(delim, ":"):
+			align = "center"
+		case strings.HasSuffix(delim, ":"):
+			align = "right"
+		case strings.HasPrefix(delim, ":"):
+			align = "left"
+		}
+		aligns = append(aligns, align)
+	}
+	row := func(cells []string, tag string, num int) {
+		r.write("<tr>\n", num)
+		for col := range header {
+			var attr string
+			if aligns[col] != "" {
+				attr = ` align="` + aligns[col] + `"`
+			}
+			var cell string
+			if col < len(cells) {
+				cell = r.renderInline(cells[col])
+			}
+			r.write("<"+tag+attr+">"+cell+"</"+tag+">\n", num)
+		}
+		r.write("</tr>\n", num)
+	}
+
+	r.startBlock(lines[i].num)
+	r.write("<table>\n<thead>\n", lines[i].num)
+	row(header, "th", lines[i].num)
+	r.write("</thead>\n", lines[i].num)
+	var start int = i
+	for i += 2; i < len(lines); i++ {
+		var text string = lines[i].text
+		if isBlank(text) || interruptsParagraph(text) {
+			break
+		}
+		if i == start+2 {
+			r.write("<tbody>\n", lines[i].num)
+		}
+		row(splitTableRow(text), "td", lines[i].num)
+	}
+	if i > start+2 {
+		r.write("</tbody>\n", lines[i-1].num)
+	}
+	r.write("</table>\n", lines[i-1].num)
+	return i
+}
+
+// A piece of inline output: either HTML that's final, or a run of delimiter
+// characters (*, _ or ~) that may turn into emphasis.
+type mdInline struct {
+	html     string
+	delim    byte
+	count    int // how many of the delimiter characters are left
+	canOpen  bool
+	canClose bool
+	open     []string // tags opened after the delimiter's characters
+	close    []string // tags closed before them
+}
+
+// Renders the inline syntax of text: code spans, emphasis, links, images,
+// autolinks, raw HTML, hard line breaks, and escapes.
+func (r *mdRenderer) renderInline(text string) string {
+	var pieces []*mdInline
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			pieces = append(pieces, &mdInline{html: plain.String()})
+			plain.Reset()
+		}
+	}
+	add := func(s string) {
+		flush()
+		pieces = append(pieces, &mdInline{html: s})
+	}
+
+	for i := 0; i < len(text); {
+		var c byte = text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			add("<br />\n")
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			plain.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if code, n := codeSpan(text[i:]); n > 0 {
+				add("<code>" + html.EscapeString(code) + "</code>")
+				i += n
+				continue
+			}
+			// An unmatched run of backticks is taken literally
+			var run int = len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			plain.WriteString(text[i : i+run])
+			i += run
+			continue
+		case c == '*' || c == '_' || c == '~':
+			var run int = len(text[i:]) - len(strings.TrimLeft(text[i:],
+				string(c)))
+			flush()
+			pieces = append(pieces, delimiterRun(text, i, run))
+			i += run
+			continue
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			if label, dest, title, n := r.parseLink(text[i+1:]); n > 0 {
+				add(`<img src="` + escapeURL(dest) + `" alt="` +
+					html.EscapeString(html.UnescapeString(stripTags(
+						r.renderInline(label)))) + `"` + titleAttr(title) +
+					" />")
+				i += n + 1
+				continue
+			}
+		case c == '[':
+			if label, dest, title, n := r.parseLink(text[i:]); n > 0 {
+				add(`<a href="` + escapeURL(dest) + `"` + titleAttr(title) +
+					">" + r.renderInline(label) + "</a>")
+				i += n
+				continue
+			}
+		case c == '<':
+			if m := mdAutolink.FindString(text[i:]); m != "" {
+				var url string = m[1 : len(m)-1]
+				add(`<a href="` + escapeURL(url) + `">` +
+					html.EscapeString(url) + "</a>")
+				i += len(m)
+				continue
+			}
+			if m := mdEmailLink.FindString(text[i:]); m != "" {
+				var email string = m[1 : len(m)-1]
+				add(`<a href="mailto:` + escapeURL(email) + `">` +
+					html.EscapeString(email) + "</a>")
+				i += len(m)
+				continue
+			}
+			var raw string
+			for _, re := range []*regexp.Regexp{mdOpenTag, mdCloseTag,
+				mdComment, mdInstruction} {
+				if raw = re.FindString(text[i:]); raw != "" {
+					break
+				}
+			}
+			if raw != "" {
+				add(raw)
+				i += len(raw)
+				continue
+			}
+		case c == '&':
+			if m := mdEntity.FindString(text[i:]); m != "" {
+				plain.WriteString(m)
+				i += len(m)
+				continue
+			}
+		case c == '\n':
+			// Two or more spaces at the end of a line make a hard break
+			var line string = plain.String()
+			var trimmed string = strings.TrimRight(line, " ")
+			if len(line)-len(trimmed) >= 2 {
+				plain.Reset()
+				plain.WriteString(trimmed)
+				add("<br />\n")
+			} else {
+				plain.Reset()
+				plain.WriteString(trimmed + "\n")
+			}
+			i++
+			// Leading spaces on the next line are ignored
+			for i < len(text) && text[i] == ' ' {
+				i++
+			}
+			continue
+		}
+		plain.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	flush()
+
+	processEmphasis(pieces)
+	var out strings.Builder
+	for _, p := range pieces {
+		for _, tag := range p.close {
+			out.WriteString(tag)
+		}
+		if p.delim != 0 {
+			out.WriteString(strings.Repeat(string(p.delim), p.count))
+		} else {
+			out.WriteString(p.html)
+		}
+		for _, tag := range p.open {
+			out.WriteString(tag)
+		}
+	}
+	return out.String()
+}
+
+// Returns the run of n delimiter characters at text[i:], with whether it can
+// open and close emphasis worked out from the characters around it (see
+// CommonMark's "left-flanking" and "right-flanking" delimiter runs).
+func delimiterRun(text string, i int, n int) *mdInline {
+	var before, after rune = ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(text[:i])
+	}
+	if i+n < len(text) {
+		after, _ = utf8.DecodeRuneInString(text[i+n:])
+	}
+	var leftFlanking bool = !unicode.IsSpace(after) &&
+		(!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	var rightFlanking bool = !unicode.IsSpace(before) &&
+		(!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	var run = &mdInline{delim: text[i], count: n}
+	if text[i] == '_' {
+		// Underscores within words (snake_case) aren't emphasis
+		run.canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		run.canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	} else {
+		run.canOpen = leftFlanking
+		run.canClose = rightFlanking
+	}
+	return run
+}
+
+// Turns matching delimiter runs within pieces into <em>, <strong> and <del>
+// tags, following CommonMark's "process emphasis" procedure.
+func processEmphasis(pieces []*mdInline) {
+	for closer := 0; closer < len(pieces); closer++ {
+		var c *mdInline = pieces[closer]
+		if c.delim == 0 || !c.canClose || c.count == 0 {
+			continue
+		}
+		for opener := closer - 1; opener >= 0 && c.count > 0; opener-- {
+			var o *mdInline = pieces[opener]
+			if o.delim != c.delim || !o.canOpen || o.count == 0 {
+				continue
+			}
+			if c.delim == '~' && (o.count < 2 || c.count < 2) {
+				continue
+			}
+			// The "rule of 3" keeps `*foo**bar*` from matching oddly
+			if (o.canClose || c.canOpen) && (o.count+c.count)%3 == 0 &&
+				!(o.count%3 == 0 && c.count%3 == 0) {
+				continue
+			}
+
+			var n int = 1
+			var tag string = "em"
+			if c.delim == '~' {
+				n, tag = 2, "del"
+			} else if o.count >= 2 && c.count >= 2 {
+				n, tag = 2, "strong"
+			}
+			o.count -= n
+			c.count -= n
+			o.open = append([]string{"<" + tag + ">"}, o.open...)
+			c.close = append(c.close, "</"+tag+">")
+			// Delimiters in between can't match anything outside of this
+			for _, p := range pieces[opener+1 : closer] {
+				if p.delim != 0 {
+					p.canOpen, p.canClose = false, false
+				}
+			}
+			opener++ // the same opener may have characters left
+		}
+	}
+}
+
+// Returns the content of the code span at the start of text, along with its
+// length. n is 0 if text doesn't start with a complete code span.
+func codeSpan(text string) (code string, n int) {
+	var run int = len(text) - len(strings.TrimLeft(text, "`"))
+	for i := run; i < len(text); {
+		idx := strings.Index(text[i:], strings.Repeat("`", run))
+		if idx == -1 {
+			return "", 0
+		}
+		var start int = i + idx
+		var end int = start + len(text[start:]) -
+			len(strings.TrimLeft(text[start:], "`"))
+		if end-start != run {
+			i = end
+			continue
+		}
+		code = strings.Replace(text[run:start], "\n", " ", -1)
+		if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' &&
+			strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		return code, end
+	}
+	return "", 0
+}
+
+// Parses the link at the start of text, returning its parts along with its
+// length. That's either an inline link, `[label](destination "title")`, or a
+// reference link whose destination and title come from a link reference
+// definition: `[label][ref]`, `[label][]` or `[label]`. n is 0 if text doesn't
+// start with a complete link.
+func (r *mdRenderer) parseLink(text string) (label string, dest string,
+	title string, n int) {
+	// The label ends at the matching "]", skipping escaped brackets and the
+	// brackets within code spans
+	var depth int = 0
+	var end int = -1
+	for i := 0; i < len(text) && end == -1; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '`':
+			if _, length := codeSpan(text[i:]); length > 0 {
+				i += length - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end == -1 {
+		return "", "", "", 0
+	}
+	label = text[1:end]
+	if end+1 < len(text) && text[end+1] == '(' {
+		if dest, title, n := inlineLinkTarget(text, end+2); n > 0 {
+			return label, dest, title, n
+		}
+	}
+
+	// [label][ref] and [label][] (where the label is the ref), or else
+	// [label] on its own
+	var ref string = label
+	n = end + 1
+	if end+1 < len(text) && text[end+1] == '[' {
+		close := strings.IndexAny(text[end+2:], "[]")
+		if close != -1 && text[end+2+close] == ']' {
+			if inner := text[end+2 : end+2+close]; inner != "" {
+				ref = inner
+			}
+			n = end + 3 + close
+		}
+	}
+	def, ok := r.refs[normalizeLabel(ref)]
+	if !ok {
+		return "", "", "", 0
+	}
+	return label, def.dest, def.title, n
+}
+
+// Parses the destination and title of an inline link, `destination "title")`,
+// starting at text[i] (right after the "("). Returns the index right after
+// the ")" as n, which is 0 if they aren't complete.
+func inlineLinkTarget(text string, i int) (dest string, title string,
+	n int) {
+	i = skipLinkSpace(text, i)
+	if i < len(text) && text[i] == '<' {
+		close := strings.IndexAny(text[i:], ">\n")
+		if close == -1 || text[i+close] != '>' {
+			return "", "", 0
+		}
+		dest = text[i+1 : i+close]
+		i += close + 1
+	} else {
+		var parens int = 0
+		var start int = i
+		for ; i < len(text); i++ {
+			if text[i] == '\\' && i+1 < len(text) {
+				i++
+				continue
+			}
+			if text[i] == '(' {
+				parens++
+			} else if text[i] == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			} else if text[i] <= ' ' {
+				break
+			}
+		}
+		dest = text[start:i]
+	}
+
+	var afterDest int = i
+	i = skipLinkSpace(text, i)
+	if i < len(text) && i > afterDest && strings.IndexByte(`"'(`,
+		text[i]) != -1 {
+		var closing byte = text[i]
+		if closing == '(' {
+			closing = ')'
+		}
+		close := strings.IndexByte(text[i+1:], closing)
+		if close == -1 {
+			return "", "", 0
+		}
+		title = text[i+1 : i+1+close]
+		i = skipLinkSpace(text, i+2+close)
+	}
+	if i >= len(text) || text[i] != ')' {
+		return "", "", 0
+	}
+	return unescapeMarkdown(dest), unescapeMarkdown(title), i + 1
+}
+
+func skipLinkSpace(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t' ||
+		text[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// Returns s with its backslash escapes resolved.
+func unescapeMarkdown(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		out.WriteByte(s[i])
+	}
+	return html.UnescapeString(out.String())
+}
+
+// Escapes url for use within an attribute, percent-encoding the spaces and
+// other characters that can't appear in a URL as-is.
+func escapeURL(url string) string {
+	var out strings.Builder
+	for i := 0; i < len(url); i++ {
+		var c byte = url[i]
+		if c <= ' ' || c >= 0x7f || c == '"' || c == '<' || c == '>' ||
+			c == '\\' || c == '`' {
+			out.WriteString("%" + strings.ToUpper(strconv.FormatInt(
+				int64(c)|0x100, 16)[1:]))
+			continue
+		}
+		if c == '&' {
+			out.WriteString("&amp;")
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+func titleAttr(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` title="` + html.EscapeString(title) + `"`
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < 0x80 && c > ' ' && !isASCIILetter(c) && !(c >= '0' && c <= '9')
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
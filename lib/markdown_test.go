@@ -0,0 +1,46 @@
+package lib
+
+import "testing"
+
+func TestMarkdownToHTML(t *testing.T) {
+	var tests = []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"paragraph", "Hello *world*", "<p>Hello <em>world</em></p>\n"},
+		{"inline link", "[a](/b \"T\")",
+			"<p><a href=\"/b\" title=\"T\">a</a></p>\n"},
+		{"full reference", "[text][ref]\n\n[ref]: /url",
+			"<p><a href=\"/url\">text</a></p>\n"},
+		{"collapsed reference", "[Foo][]\n\n[foo]: /url \"Title\"",
+			"<p><a href=\"/url\" title=\"Title\">Foo</a></p>\n"},
+		{"shortcut reference", "See [the  docs].\n\n[The docs]: <a b>",
+			"<p>See <a href=\"a%20b\">the  docs</a>.</p>\n"},
+		{"defined first", "[ref]: /url\n[ref]",
+			"<p><a href=\"/url\">ref</a></p>\n"},
+		{"first definition wins", "[a]\n\n[a]: /one\n[a]: /two",
+			"<p><a href=\"/one\">a</a></p>\n"},
+		{"title on the next line", "[a]\n\n[a]: /url\n  'Title'",
+			"<p><a href=\"/url\" title=\"Title\">a</a></p>\n"},
+		{"not a title", "[a]\n\n[a]: /url\n\"Title\" more",
+			"<p><a href=\"/url\">a</a></p>\n<p>&#34;Title&#34; more</p>\n"},
+		{"image reference", "![logo][img]\n\n[img]: /logo.png",
+			"<p><img src=\"/logo.png\" alt=\"logo\" /></p>\n"},
+		{"undefined", "[nothing] and [a][nothing]",
+			"<p>[nothing] and [a][nothing]</p>\n"},
+		{"in a list", "- [a]\n\n[a]: /url",
+			"<ul>\n<li><a href=\"/url\">a</a></li>\n</ul>\n"},
+		{"within a paragraph", "text\n[a]: /url",
+			"<p>text\n[a]: /url</p>\n"},
+		{"code span", "`[a]`\n\n[a]: /url", "<p><code>[a]</code></p>\n"},
+		{"heading id", "## Getting started",
+			"<h2 id=\"getting-started\">Getting started</h2>\n"},
+	}
+	for _, test := range tests {
+		if got, _ := MarkdownToHTML(test.src); got != test.want {
+			t.Errorf("%s: MarkdownToHTML(%q) = %q, want %q", test.name,
+				test.src, got, test.want)
+		}
+	}
+}
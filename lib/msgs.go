@@ -1,6 +1,10 @@
 package lib
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
 
 func criticalMsg(message string) string {
 	return Style("bold", Style("red", "(!!) "+message))
@@ -27,9 +31,16 @@ func infoMsg(message string) string {
 }
 
 func FmtPrint(message string, fmtTypes ...string) {
+	FmtFprint(os.Stdout, message, fmtTypes...)
+}
+
+// Same as FmtPrint, but prints to w. Status messages are printed to
+// os.Stderr so that they don't get mixed up with output meant for other
+// programs (e.g. `webes validate --format=json`).
+func FmtFprint(w io.Writer, message string, fmtTypes ...string) {
 	// Print the now completely formatted message
 	for _, fmtType := range fmtTypes {
-		fmt.Println(Fmt(message, fmtType))
+		fmt.Fprintln(w, Fmt(message, fmtType))
 	}
 }
 
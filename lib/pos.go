@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// A position within a source file. Offset is the byte offset from the start of
+// the source, while Line and Col are 1-based, with Col counted in characters.
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// Converts byte offsets within a source into positions.
+type LineIndex struct {
+	src    string
+	starts []int // the offset each line starts at
+}
+
+func NewLineIndex(src string) *LineIndex {
+	var idx = &LineIndex{src: src, starts: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			idx.starts = append(idx.starts, i+1)
+		}
+	}
+	return idx
+}
+
+// Returns the position of offset. Offsets past the end of the source are
+// clamped to its end.
+func (idx *LineIndex) Pos(offset int) Pos {
+	if offset > len(idx.src) {
+		offset = len(idx.src)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	// The last line that starts at or before offset
+	line := sort.Search(len(idx.starts), func(i int) bool {
+		return idx.starts[i] > offset
+	}) - 1
+	col := utf8.RuneCountInString(idx.src[idx.starts[line]:offset]) + 1
+	return Pos{Offset: offset, Line: line + 1, Col: col}
+}
+
+// Returns the offset that the 1-based line starts at.
+func (idx *LineIndex) Offset(line int) int {
+	if line < 1 {
+		return 0
+	}
+	if line > len(idx.starts) {
+		return len(idx.src)
+	}
+	return idx.starts[line-1]
+}
+
+// Returns the text of the 1-based line, without its line break.
+func (idx *LineIndex) Line(line int) string {
+	if line < 1 || line > len(idx.starts) {
+		return ""
+	}
+	var end int = len(idx.src)
+	if line < len(idx.starts) {
+		end = idx.starts[line] - 1
+	}
+	var text string = idx.src[idx.starts[line-1]:end]
+	if len(text) > 0 && text[len(text)-1] == '\r' {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+// Returns the number of lines in the source.
+func (idx *LineIndex) Lines() int {
+	return len(idx.starts)
+}
@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// The JSON form of a list of diagnostics, as printed by
+// `webes validate --format=json`.
+type jsonReport struct {
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+}
+type jsonDiagnostic struct {
+	Rule     string    `json:"rule"`
+	Code     string    `json:"code,omitempty"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	File     string    `json:"file"`
+	Range    jsonRange `json:"range"`
+}
+type jsonRange struct {
+	Start jsonPos `json:"start"`
+	End   jsonPos `json:"end"`
+}
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Encodes diags as a JSON document, along with how many of them are errors
+// and warnings.
+func DiagnosticsJSON(diags []Diagnostic) ([]byte, error) {
+	var report = jsonReport{Diagnostics: []jsonDiagnostic{}}
+	for _, d := range diags {
+		end := d.End()
+		report.Diagnostics = append(report.Diagnostics, jsonDiagnostic{
+			Rule:     d.Rule,
+			Code:     d.Code,
+			Severity: d.Severity,
+			Message:  d.Message,
+			File:     d.File,
+			Range: jsonRange{
+				Start: jsonPos{d.Pos.Line, d.Pos.Col},
+				End:   jsonPos{end.Line, end.Col},
+			},
+		})
+		switch d.Severity {
+		case "error":
+			report.Errors++
+		case "warning":
+			report.Warnings++
+		}
+	}
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// The subset of SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/)
+// that webes produces.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// Encodes diags as a SARIF log, which CI services such as GitHub code
+// scanning turn into annotations. rules describes each rule id that diags
+// may use.
+func DiagnosticsSARIF(diags []Diagnostic, rules map[string]string) ([]byte,
+	error) {
+	var run = sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "webes",
+			InformationURI: "https://github.com/Lucas-Pichette/webes",
+			Rules:          []sarifRule{},
+		}},
+		// Columns are counted in characters, not UTF-16 code units
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+
+	var seen = make(map[string]bool)
+	for _, d := range diags {
+		if !seen[d.Rule] {
+			seen[d.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               d.Rule,
+				ShortDescription: sarifMessage{rules[d.Rule]},
+			})
+		}
+
+		var location = sarifLocation{sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{filepath.ToSlash(d.File)},
+		}}
+		if d.Pos.Line > 0 {
+			end := d.End()
+			location.PhysicalLocation.Region = &sarifRegion{
+				StartLine:   d.Pos.Line,
+				StartColumn: d.Pos.Col,
+				EndLine:     end.Line,
+				EndColumn:   end.Col,
+			}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    d.Rule,
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{d.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+// Returns the SARIF level matching severity.
+func sarifLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	}
+	return "note"
+}
@@ -0,0 +1,184 @@
+package lib
+
+import "strings"
+
+// Returns whether sel may match any element within root.
+//
+// Matching errs on the side of caution: anything that depends on the state of
+// the page (`:hover`, `:checked`, ...), on its structure (`:nth-child()`,
+// ...), or on selectors that can't be checked against a static tree
+// (`:not()`, `:has()`, `&`) is assumed to match, so that only selectors that
+// certainly match nothing are reported as such.
+func SelectorMatchesAny(root *HTMLNode, sel *CSSSelector) bool {
+	var found bool = false
+	root.Walk(func(n *HTMLNode) bool {
+		if !found && n.Type == HTMLElementNode && SelectorMatches(n, sel) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// Returns whether sel may match the element n (see SelectorMatchesAny).
+func SelectorMatches(n *HTMLNode, sel *CSSSelector) bool {
+	if len(sel.Compounds) == 0 {
+		return false
+	}
+	return matchCompounds(n, sel.Compounds)
+}
+
+// Matches the last of compounds against n, and the ones before it against
+// n's ancestors and siblings, according to their combinators.
+func matchCompounds(n *HTMLNode, compounds []*CSSCompound) bool {
+	var last *CSSCompound = compounds[len(compounds)-1]
+	if !matchCompound(n, last) {
+		return false
+	}
+	if len(compounds) == 1 {
+		return true
+	}
+	var rest []*CSSCompound = compounds[:len(compounds)-1]
+
+	switch last.Combinator {
+	case ">":
+		parent := parentElement(n)
+		return parent != nil && matchCompounds(parent, rest)
+	case "+":
+		prev := previousElement(n)
+		return prev != nil && matchCompounds(prev, rest)
+	case "~":
+		for prev := previousElement(n); prev != nil; prev = previousElement(prev) {
+			if matchCompounds(prev, rest) {
+				return true
+			}
+		}
+		return false
+	}
+	// The descendant combinator
+	for parent := parentElement(n); parent != nil; parent = parentElement(parent) {
+		if matchCompounds(parent, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether every simple selector of compound may match n.
+func matchCompound(n *HTMLNode, compound *CSSCompound) bool {
+	for _, simple := range compound.Simple {
+		if !matchSimple(n, simple) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSimple(n *HTMLNode, simple *CSSSimpleSelector) bool {
+	switch simple.Type {
+	case CSSTypeSelector:
+		return n.Is(simple.Name)
+	case CSSClassSelector:
+		return containsString(n.Classes(), simple.Name)
+	case CSSIDSelector:
+		return strings.TrimSpace(n.AttrValue("id")) == simple.Name
+	case CSSAttributeSelector:
+		return matchAttribute(n, simple.Raw)
+	case CSSPseudoClass:
+		switch simple.Name {
+		case "is", "where", "matches", "-webkit-any", "-moz-any":
+			for _, arg := range simple.Args {
+				if SelectorMatches(n, arg) {
+					return true
+				}
+			}
+			return len(simple.Args) == 0
+		case "root":
+			return n.Is("html")
+		}
+	}
+	return true
+}
+
+// Matches an attribute selector, such as `[type="text" i]`, against n.
+func matchAttribute(n *HTMLNode, raw string) bool {
+	var text string = strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+	var i int = skipSpaceIn(text, 0)
+	name, i := readCSSIdent(text, i)
+	// Namespaced attributes (`[xlink|href]`) aren't worth the trouble
+	if i < len(text) && text[i] == '|' {
+		return true
+	}
+	attr, ok := n.Attr(name)
+	if !ok {
+		return false
+	}
+
+	i = skipSpaceIn(text, i)
+	if i >= len(text) {
+		return true
+	}
+	var op string = text[i : i+1]
+	if op != "=" {
+		if i+1 >= len(text) || text[i+1] != '=' {
+			return true
+		}
+		i++
+	}
+	i = skipSpaceIn(text, i+1)
+
+	var value string
+	if i < len(text) && (text[i] == '"' || text[i] == '\'') {
+		end := strings.IndexByte(text[i+1:], text[i])
+		if end == -1 {
+			return true
+		}
+		value = text[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		value, i = readCSSIdent(text, i)
+	}
+	var actual string = attr.Value
+	if strings.EqualFold(strings.TrimSpace(text[i:]), "i") {
+		actual, value = strings.ToLower(actual), strings.ToLower(value)
+	}
+
+	switch op {
+	case "=":
+		return actual == value
+	case "~":
+		return containsString(strings.Fields(actual), value)
+	case "|":
+		return actual == value || strings.HasPrefix(actual, value+"-")
+	case "^":
+		return value != "" && strings.HasPrefix(actual, value)
+	case "$":
+		return value != "" && strings.HasSuffix(actual, value)
+	case "*":
+		return value != "" && strings.Contains(actual, value)
+	}
+	return true
+}
+
+func parentElement(n *HTMLNode) *HTMLNode {
+	if n.Parent == nil || n.Parent.Type != HTMLElementNode {
+		return nil
+	}
+	return n.Parent
+}
+
+func previousElement(n *HTMLNode) *HTMLNode {
+	if n.Parent == nil {
+		return nil
+	}
+	var prev *HTMLNode
+	for _, sibling := range n.Parent.Children {
+		if sibling == n {
+			return prev
+		}
+		if sibling.Type == HTMLElementNode {
+			prev = sibling
+		}
+	}
+	return nil
+}
@@ -1,20 +1,19 @@
 package main
 
 import (
-	"fmt"       // Used for printing
-	"io/ioutil" // Used for reading files and directories
-	"os"        // Used for creating files and directories
-	"strings"   // Used for string manipulation
+	"bufio"         // Used for reading the user's answers
+	"errors"        // Used for telling errors apart
+	"fmt"           // Used for printing
+	"html"          // Used for decoding attribute values
+	"io/fs"         // Used for telling missing directories apart
+	"os"            // Used for creating files and directories
+	"path/filepath" // Used for building file paths
+	"strings"       // Used for string manipulation
+	"unicode/utf8"  // Used for measuring identifiers
 
 	"webes/lib" // Used for various utility functions specific to webes
 )
 
-// The structure of a command that the user can execute.
-type Command struct {
-	function    func()
-	description string
-}
-
 // 3 sections of a .webes file: <template>...,<style>..., and <script>...
 // this struct will contain all the information for each of these sections
 type parsedFileData struct {
@@ -23,16 +22,37 @@ type parsedFileData struct {
 	scriptData   parsedScriptData
 }
 type parsedTemplateData struct {
-	classes []string
-	ids     []string
-	jsFuncs []string
+	classes []ident
+	ids     []ident
+	jsFuncs []ident // functions called by event handlers
+	jsRefs  []ident // every identifier referenced by event handlers
+	props   []ident // {{ name }} placeholders of props
 }
 type parsedStyleData struct {
-	classes []string
-	ids     []string
+	classes []ident
+	ids     []ident
 }
 type parsedScriptData struct {
-	jsFuncs []string
+	jsFuncs []ident // functions and methods defined by the script
+	jsRefs  []ident // every identifier referenced by the script
+}
+
+// A class, id, or function name found by scan(), along with where it was
+// found within its section.
+type ident struct {
+	name   string
+	offset int
+	length int // how many characters it spans, e.g. 6 for `.title`
+}
+
+// A single piece of unused code found by validateComponent().
+type finding struct {
+	component *component // the component the finding belongs to
+	section   string     // "template", "props", "style" or "script"
+	kind      string     // "class", "id", "function" or "prop"
+	name      string
+	pos       lib.Pos // where name was found within the component's file
+	length    int
 }
 
 // The basic structure of a file-to-be-created.
@@ -45,228 +65,344 @@ type fileT struct {
 // The global commands-map that is filled in upon webes launch w/
 // runCommandInitializtion().
 var commands = make(map[string]Command)
-var pwd string
 
 func main() {
-	pwd, err := os.Getwd()
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("PWD: ", pwd)
 	commandHandler()
 }
 
 /* */
 /* === WEBES COMMANDS === */
 /* */
-// Creates a new boilerplate HTML file in PWD
-func webes_boilerplate() {
-	var boilerplate string = "<!DOCTYPE HTML>\n<html lang='en-us'>" +
-		"</html>\n<head>\n	<title></title>\n	<!--Metadata-->\n	<" +
-		"meta charset='UTF-8'>\n	<meta name='viewport' content='wi" +
-		"dth=device-width, initial-scale=1'>\n	<meta name=robots con" +
-		"tent='index,follow'>\n	<meta name='description' content='!WE" +
-		"BSITE_DESCRIPTION'>\n	<meta name='keywords' content='!RELEV" +
-		"ANT_KEYWORDS'>\n	<meta name='author' content='!AUTHOR_NAME" +
-		"'>\n	<meta rel='canonical' href='!YOUR_URL'>\n	<meta nam" +
-		"e='subject' content='!WEBSITE_SUBJECT'>\n	<meta name='url' " +
-		"content='!YOUR_URL'>\n	<meta http-equiv='Expires' content='0" +
-		"'>\n	<meta http-equiv='imagetoolbar' content='no'>\n\n	<" +
-		"!--Twitter Card Specs-->\n	<meta name='twitter:card' content" +
-		"='summary'>\n	<meta name='twitter:site' content='@!YOUR_TWI" +
-		"TTER_HANDLE'>\n	<meta name='twitter:title' content='!YOUR" +
-		"_TITLE'>\n	<meta name='twitter:description' content='!YOUR_D" +
-		"ESCRIPTION'>\n	<meta name='twitter:image' content='!PATH_FOR" +
-		"_DISPLAY_IMAGE'>\n\n	<!--Open Graph Specs-->\n	<meta pro" +
-		"perty='og:title' content='!YOUR_TITLE'/>\n	<meta property='o" +
-		"g:type' content='article'/>\n	<meta property='og:url' conte" +
-		"nt='!YOUR_URL'/>\n	<meta property='og:image' content='!PATH_" +
-		"FOR_DISPLAY_IMAGE'/>\n	<meta property='og:description' conte" +
-		"nt='!YOUR_DESCRIPTION'/>\n	<meta property='og:site_name' con" +
-		"tent='!YOUR_WEBSITE_NAME'/>\n\n	<!--Dependencies-->\n	<" +
-		"link rel='stylesheet' href='styles/style.css'>\n</head>\n<bo" +
-		"dy>\n	\n\t<!--Non-Critical Dependencies-->\n	<script langu" +
-		"age=\"javascript\" type=\"text/javascript\" src=\"scripts/sc" +
-		"ript.js\"></script>\n</body>\n</html>"
-
-	var fName string
-	fmt.Scanln(&fName)
+// Creates a new boilerplate HTML file in PWD, asking for its name unless it's
+// given
+// Callable via `webes boilerplate [name]`
+func webes_boilerplate(call *commandCall) error {
+	// The metadata is filled in from the front matter when the page is built
+	var boilerplate string = "---\ntitle: \ndescription: \nkeywords: \n" +
+		"image: \ncanonical: \nrobots: index,follow\nog:type: article\n" +
+		"---\n<!DOCTYPE HTML>\n<html lang='en-us'>\n<head>\n	<!--Metadata-->" +
+		"\n	<meta charset='UTF-8'>\n	<meta name='viewport' " +
+		"content='width=device-width, initial-scale=1'>\n\n	" +
+		"<!--Dependencies-->\n	<link rel='stylesheet' " +
+		"href='/styles/style.css'>\n</head>\n<body>\n	\n	" +
+		"<!--Non-Critical Dependencies-->\n	<script " +
+		"src='/scripts/script.js'></script>\n</body>\n</html>\n"
+
+	var fName string = call.arg(0)
+	if fName == "" {
+		fmt.Print("Name of the new file: ")
+		fmt.Scanln(&fName)
+	}
+	if fName == "" {
+		return &usageError{call.name, "Missing the name of the new file"}
+	}
 
 	fileData := []byte(boilerplate)
 	if strings.Index(fName, ".html") == -1 {
-		err := os.WriteFile(pwd+fName+".html", fileData, 0644)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		err := os.WriteFile(pwd+fName, fileData, 0644)
-		if err != nil {
-			panic(err)
-		}
+		fName += ".html"
+	}
+	if err := os.WriteFile(fName, fileData, 0644); err != nil {
+		return writeError(fName, err)
 	}
+	return nil
 }
 
 // Initializes a new webes project
 // Callable via `webes init`
-func webes_init() {
+func webes_init(call *commandCall) error {
 	lib.FmtPrint("initializing Project", "header", "info")
 	const projectTree string = "" +
 		"<pwd>\n" +
+		"	┣━ webes.json\n" +
 		"	┣━ dist/\n" +
 		"		┣━ imgs/\n" +
 		"		┣━ pages/\n" +
 		"		┣━ scripts/\n" +
-		"		┣━ styles/\n" +
-		"		┗━ index.html\n" +
+		"		┗━ styles/\n" +
 		"	┗━ dev/\n" +
 		"		┣━ components/\n" +
 		"			┗━ _helloWorld.webes\n" +
 		"		┣━ imgs/\n" +
+		"		┣━ layouts/\n" +
+		"			┗━ default.webes\n" +
 		"		┣━ pages/\n" +
+		"			┗━ index.html\n" +
 		"		┣━ scripts/\n" +
 		"			┗━ script.js\n" +
 		"		┗━ styles/\n" +
 		"			┗━ style.css\n"
 
-	makeProjectTree()
+	if err := makeProjectTree(); err != nil {
+		return err
+	}
 
 	// Now that we've made all of the directories, inform
 	// the user of the changes.
 	lib.FmtPrint("New Project with Directory Tree:", "info")
-	fmt.Println(projectTree)
+	fmt.Print(projectTree)
+	return nil
+}
+
+// Provides details about the various webes commands, or about a single one
+// along with its flags and examples
+// Callable via `webes help [command]`
+func webes_help(call *commandCall) error {
+	if name := call.arg(0); name != "" {
+		cmd, ok := commands[name]
+		if !ok {
+			return &usageError{call.name, "Command \"" + name +
+				"\" not found"}
+		}
+		printCommandHelp(name, cmd)
+		return nil
+	}
+	printCommandList()
+	return nil
 }
 
-// Provides details about the various webes commands
-// Callable via `webes help`
-func webes_help() {
+// Prints every command, sorted, along with its usage.
+func printCommandList() {
 	lib.FmtPrint("Available Commands", "header", "info")
-	for name, cmd := range commands {
-		lib.FmtPrint(name+": "+cmd.description, "info")
+	for _, name := range commandNames() {
+		var cmd Command = commands[name]
+		lib.FmtPrint(commandUsage(name, cmd), "info")
+		fmt.Println("	" + cmd.description)
 	}
+	fmt.Println()
+	fmt.Println("Run `webes help <command>` for its flags and examples.")
 }
 
 // Runs through dev/ directory and sub-directory validating HTML, CSS, and JS
 // files to ensure that nothing exists that is not being used. Skips over
 // comments.
 // webes_validate automatically called when going to `webes build`.
-func webes_validate() {
-	// 1) Scan through component files (*.webes)
-	files, err := ioutil.ReadDir("dev/components")
+// Callable via `webes validate [--format=text|json|sarif]`, and fails if any
+// errors are found.
+func webes_validate(call *commandCall) error {
+	var format string = call.stringFlag("format")
+
+	// Diagnostics are printed as they're found in the text format, and all
+	// at once otherwise. webes.json can change their severity, or turn them
+	// off.
+	var diags []lib.Diagnostic
+	report := func(d lib.Diagnostic) {
+		d, ok := project.config.applyRule(d)
+		if !ok {
+			return
+		}
+		if d.Code == "" {
+			d.Code = ruleCode(d.Rule)
+		}
+		d.File = project.rel(d.File)
+		diags = append(diags, d)
+		if format == "text" {
+			lib.PrintDiagnostic(d)
+		}
+	}
+	// Errors that would stop the build are reported with the code they'd
+	// stop it with, or else code, pointing at file unless they know better
+	reportError := func(err error, code string, file string) {
+		var d lib.Diagnostic = errorDiagnostic(err, code)
+		if d.File == "" {
+			d.File = file
+		}
+		report(d)
+	}
+	header := func(file string) {
+		if format == "text" {
+			fmt.Println(file)
+		}
+	}
+
+	// 1) Scan through component files (*.webes), including the ones within
+	// subdirectories of dev/components
+	paths, err := findComponentFiles()
 	if err != nil {
-		lib.FmtPrint(err.Error(), "error")
-		panic(err.Error())
-	}
-
-	for _, f := range files {
-		// The whole point of looking throught the files is to get data
-		const maxFileSize int = 99999
-		var fileData = make([]byte, maxFileSize)
-		// and even further, to get the parsed data
-		var pfd parsedFileData
-
-		if f.IsDir() {
-			fmt.Println("dev/components/" + f.Name())
-
-			// Inform the user that at the moment they shouldn't have components
-			// in sub-directories of dev/components/. That's a TODO for later.
-			lib.FmtPrint("Directory within dev/components found with name: \""+
-				f.Name()+"\", please ensure that you have all components in "+
-				"dev/components, as opposed to nested within a sub-directory"+
-				" within dev/components", "warning")
+		reportError(err, "E301", devPath("components"))
+	}
+
+	var components = make(map[string]*component)
+	var ordered []*component
+	for _, path := range paths {
+		header(project.rel(path))
+
+		c, err := readComponent(path)
+		if err != nil {
+			reportError(err, "E301", path)
+			continue
+		}
+		if other, ok := components[componentKey(c.name)]; ok {
+			reportError(collisionError(other, c), "", c.path)
 		} else {
-			fmt.Println("dev/components/" + f.Name() + "/")
-
-			var fileExtension = strings.SplitAfter(f.Name(), ".")[1]
-			// Start the parsing if it's not a directory, and has *.webes format
-			if fileExtension == "webes" {
-				file, err := os.Open(pwd + "dev/components/" + f.Name())
-				if err != nil {
-					panic(err)
-				} else {
-					// if length is needed, replace _ w/ length
-					_, err := file.Read(fileData)
-					if err != nil {
-						panic(err)
-					} else {
-						// read was successful
-					}
-				}
-			}
+			components[componentKey(c.name)] = c
+		}
+		ordered = append(ordered, c)
+		for _, fd := range validateComponent(c) {
+			report(fd.diagnostic())
+		}
+	}
 
-			// Now that we have the file data, parse through it
-			var fileStr string = string(fileData)
-			scan(fileStr, "template", &pfd)
-			scan(fileStr, "style", &pfd)
-			scan(fileStr, "script", &pfd)
-			fmt.Println("Completed all scans...")
-
-			// 	1.d) Cross-compare what's used (collected from <template>...)
-			//		with what exists (collected from <style>... and <script>...)
-
-			// compare template classes with style classes
-			for _, st_c := range pfd.styleData.classes {
-				if !contains(pfd.templateData.classes, st_c) {
-					// Add removal logic for that class in <style>
-					// Add logic to determine file name, line number,
-					// and column number as well
-					lib.FmtPrint("Found unused class in style", "warning")
-				}
-			}
-			// compare template ids with style ids
-			for _, st_i := range pfd.styleData.ids {
-				if contains(pfd.templateData.ids, st_i) {
-					// Add removal logic for that id in <style>
-					// Add logic to determine file name, line number,
-					// and column number as well
-					lib.FmtPrint("Found unused id in script", "warning")
-				}
-			}
-			// compare template functions with script functions
-			for _, sc_f := range pfd.scriptData.jsFuncs {
-				if contains(pfd.templateData.jsFuncs, sc_f) {
-					// Add removal logic for that function in <script>
-					// Add logic to determine file name, line number,
-					// and column number as well
-					lib.FmtPrint("Found unused function in script", "warning")
-				}
-			}
-			// compare style classes with template classes
-			for _, t_c := range pfd.templateData.classes {
-				if !contains(pfd.styleData.classes, t_c) {
-					// Add removal logic for that class in <template>
-					// Add logic to determine file name, line number,
-					// and column number as well
-					lib.FmtPrint("Found unused class in template", "warning")
-				}
-			}
-			// compare style ids with template ids
-			for _, t_i := range pfd.templateData.ids {
-				if !contains(pfd.styleData.ids, t_i) {
-					// Add removal logic for that id in <template>
-					// Add logic to determine file name, line number,
-					// and column number as well
-					lib.FmtPrint("Found unused id in template", "warning")
-				}
-			}
-			// compare script funtions with template functions
-			for _, t_f := range pfd.templateData.jsFuncs {
-				if !contains(pfd.scriptData.jsFuncs, t_f) {
-					// Add removal logic for that function in <template>
-					// Add logic to determine file name, line number,
-					// and column number as well
-					lib.FmtPrint("Found unused function in template", "warning")
-				}
+	// 2) Make sure that every component included by another one exists, is
+	// given the props and slots it needs, and that no component ends up
+	// including itself
+	var includeGraph *depGraph = newDepGraph(components)
+	var cycle []*component = includeGraph.findCycle()
+	if cycle != nil {
+		inc := findInclude(includeGraph.includes[cycle[0]], cycle[1])
+		report(lib.Diagnostic{Rule: "component-error", Code: "E202",
+			Severity: "error", File: cycle[0].path, Pos: inc.pos,
+			Len: inc.length, Source: cycle[0].src,
+			Message: "component cycle: " + componentChain(cycle)})
+	}
+	for _, c := range ordered {
+		c := c
+		diags := checkUsage(lib.ParseHTML(c.template), components, c.path,
+			c.src, func(offset int) lib.Pos {
+				return c.pos("template", offset)
+			})
+		for _, d := range diags {
+			report(d)
+		}
+		// Components would be expanded endlessly if there's a cycle
+		if hasErrors(diags) || cycle != nil {
+			continue
+		}
+		_, err := expandIncludes(lib.ParseHTML(c.template), components)
+		if err != nil {
+			reportError(err, "", c.path)
+		}
+	}
+
+	// 3) Report the files in dev/imgs, dev/scripts, and dev/styles that no
+	// page refers to, once every page has been expanded the way that the build
+	// would. A page that can't be expanded would make everything it refers to
+	// look unused, so nothing is reported at all then.
+	pages, err := findPages()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		reportError(err, "E301", devPath("pages"))
+	}
+	var docs []*lib.HTMLNode
+	var expanded bool = err == nil
+	for _, p := range pages {
+		src, err := readPage(p)
+		if err != nil {
+			reportError(err, "E301", p.src)
+			expanded = false
+			continue
+		}
+		diags := checkUsage(parsePage(src, components), components,
+			p.src, src.file, src.lines.Pos)
+		for _, d := range diags {
+			report(d)
+		}
+		if hasErrors(diags) {
+			expanded = false
+			continue
+		}
+		doc, used, err := expandPage(src, components)
+		if err != nil {
+			// Pages that run into the cycle reported above don't report it
+			// again
+			if cycle == nil || errorCode(err) != "E202" {
+				reportError(err, "", p.src)
 			}
+			expanded = false
+			continue
+		}
+		if placeholder, where, ok := findPlaceholder(doc); ok {
+			report(lib.Diagnostic{Rule: "placeholder", Code: "E206",
+				Severity: "error", File: p.src,
+				Message: "Found the placeholder " + placeholder +
+					" (in " + where + "), which would end up in " +
+					project.rel(p.dest)})
+		}
+		// Only the url()s within the components' styles matter here
+		var styles = make(map[*component]string)
+		for _, c := range used {
+			styles[c] = c.style
+		}
+		inlineComponents(doc, used, styles, nil)
+		docs = append(docs, doc)
+	}
+	if expanded {
+		unused, err := buildSiteGraph(pages, docs).unusedAssets()
+		if err != nil {
+			reportError(err, "E301", devPath())
+		}
+		for _, asset := range unused {
+			report(lib.Diagnostic{Rule: "unused-asset", Severity: "warning",
+				File: devPath(filepath.FromSlash(asset)),
+				Message: "Found unused file dev/" + asset + ", which no page " +
+					"refers to"})
+		}
+	}
+
+	var errorCount int = 0
+	for _, d := range diags {
+		if d.Severity == "error" {
+			errorCount++
 		}
 	}
+	var out []byte
+	switch format {
+	case "json":
+		out, err = lib.DiagnosticsJSON(diags)
+	case "sarif":
+		out, err = lib.DiagnosticsSARIF(diags, validateRules)
+	}
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		fmt.Println(string(out))
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("Found %d error(s)", errorCount)
+	}
+	return nil
+}
 
+// Describes each rule that webes_validate reports diagnostics for.
+var validateRules = map[string]string{
+	"unused-class": "A class is only found in one section of a component",
+	"unused-id":    "An id is only found in one section of a component",
+	"unused-function": "A function is only found in one section of a " +
+		"component",
+	"unused-prop": "A prop is only found in one section of a component",
+	"unused-asset": "A file in dev/imgs, dev/scripts or dev/styles that no " +
+		"page refers to",
+	"read-error":  "A file or directory couldn't be read",
+	"write-error": "A file or directory couldn't be written",
+	"project-error": "There's no project within or above the working " +
+		"directory",
+	"config-error":    "webes.json is invalid",
+	"usage-error":     "A command is given a page that doesn't exist",
+	"component-error": "A component can't be included",
+	"prop-error":      "A component is given props that it doesn't accept",
+	"unknown-slot": "A component is given content for a slot that it " +
+		"doesn't have",
+	"placeholder": "A placeholder such as !YOUR_URL would end up in a " +
+		"built page",
+	"front-matter-error": "A page's front matter is invalid, or has " +
+		"nowhere to go",
+	"page-error": "Two pages would be built to the same file",
 }
 
-func webes_wipe() {
-	var userInput string
+// Returns whether any of diags is an error rather than a warning.
+func hasErrors(diags []lib.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
 
-	lib.FmtPrint("FOR THIS COMMAND TO WORK YOU MUST BE IN THE ROOT OF YOUR "+
-		"PROJECT (same directory as dev/ and dist/).", "warning")
+// Deletes the project, once the user confirms that they want it gone
+// Callable via `webes wipe`
+func webes_wipe(call *commandCall) error {
+	lib.FmtPrint("This deletes the webes project at "+project.root+", "+
+		"along with its "+configFile+".", "warning")
 
 	var confirmationMessage string = lib.Style("underline", "This is an "+
 		"irreversible action.") +
@@ -274,68 +410,65 @@ func webes_wipe() {
 			"permanently delete this webes project (yes/no): "))
 	confirmationMessage = lib.Fmt(confirmationMessage, "critical")
 
-	// confirm with the user that they want to wipe the project
-	fmt.Print(confirmationMessage)
-	fmt.Scanln(&userInput)
-
-	// if the user didn't enter an acceptable input, reprompt them until they do
-	for strings.ToLower(userInput)[0] != 121 && // 121 == 'y'
-		strings.ToLower(userInput)[0] != 110 { // 110 == 'n'
+	// confirm with the user that they want to wipe the project, reprompting
+	// them until they enter an acceptable input. Running out of input (e.g.
+	// with nothing piped in) counts as a no.
+	var reader *bufio.Reader = bufio.NewReader(os.Stdin)
+	var answer byte
+	for answer != 'y' && answer != 'n' {
 		fmt.Print(confirmationMessage)
-		fmt.Scanln(&userInput)
+		userInput, err := reader.ReadString('\n')
+		userInput = strings.ToLower(strings.TrimSpace(userInput))
+		if userInput != "" {
+			answer = userInput[0]
+		} else if err != nil {
+			fmt.Println()
+			answer = 'n'
+		}
 	}
 
-	if strings.ToLower(userInput)[0] == 121 {
-		var pathToRemove = []string{"dev/", "dist/", "index.html"}
+	if answer == 'y' {
+		var pathToRemove = []string{devPath(), distPath(),
+			filepath.Join(project.root, "index.html"),
+			filepath.Join(project.root, configFile)}
 
 		for _, path := range pathToRemove {
-			err := os.RemoveAll(pwd + path)
+			err := os.RemoveAll(path)
 			if err != nil {
-				panic(err)
+				return writeError(path, err)
 			}
 		}
 	}
+	return nil
 }
 
 /* */
 /* === Main-Level Functions === */
 /* */
+// Runs the command given on the command line, exiting with the status that
+// it ends with (see exitCode()).
 func commandHandler() {
-	// Store the commandline arguments in a usable format
-	args := os.Args[1:]
-
 	// Initialize Commands
 	runCommandInitializtion()
 
-	// Determine if user forgot to specify a webes-command
-	// (i.e. user entered `webes` as opposed to `webes init`)
-	if len(args) == 0 {
-		// If the user did forget to specify a webes-command,
-		// then inform them that they did this, and provide
-		// a list of options they could use, and exit the driver.
-		lib.FmtPrint("Command not specified", "error")
-		webes_help()
-		return
-	}
-
-	var webes_command string = args[0]
-
-	// It's useful to provide confirmation to the user, even if they don't
-	// need it 99% of the time.
-	lib.FmtPrint("Running `"+webes_command+"`...", "info")
-
-	if val, ok := commands[webes_command]; ok {
-		// found commandv0.0.3: Baby Steps
-		val.function()
-	} else {
-		// didn't find command
-		lib.FmtPrint("Command not found", "error")
-	}
+	os.Exit(runCommand(os.Args[1:]))
 }
 
 /* */
 /* === Sub-Main-Level Functions === */
 /* */
+// Returns the path of elem within the project's dev/ directory (or whichever
+// directory webes.json names instead).
+func devPath(elem ...string) string {
+	return filepath.Join(append([]string{project.dev}, elem...)...)
+}
+
+// Returns the path of elem within the project's dist/ directory (or whichever
+// directory webes.json names instead).
+func distPath(elem ...string) string {
+	return filepath.Join(append([]string{project.dist}, elem...)...)
+}
+
 func contains(s_arr []string, str string) bool {
 	for _, e := range s_arr {
 		if e == str {
@@ -345,166 +478,256 @@ func contains(s_arr []string, str string) bool {
 
 	return false
 }
-func scan(fileStr string, whichScan string, pfd *parsedFileData) {
-	// Remove filler characters created from []byte -> string casing
-	actualFileSize := strings.Index(fileStr, "#end") +
-		len("#end")
-	fileStr = fileStr[:actualFileSize]
-	// only retrieve info inbetween <template> and </template>
-	startSection := strings.Index(fileStr, "<"+whichScan+">") +
-		len("<"+whichScan+">")
-	endSection := strings.Index(fileStr, "</"+whichScan+">")
-	fileStr = fileStr[startSection:endSection]
-
-	if whichScan == "template" {
-		search(fileStr, "class=\"", "\"", &pfd.templateData.classes)
-		search(fileStr, "class='", "'", &pfd.templateData.classes)
 
-		search(fileStr, "id=\"", "\"", &pfd.templateData.ids)
-		search(fileStr, "id='", "'", &pfd.templateData.ids)
-	} else if whichScan == "style" {
-		search(fileStr, ".", "{", &pfd.styleData.classes)
-		search(fileStr, "#", "{", &pfd.styleData.ids)
-	}
-	for _, class := range pfd.templateData.classes {
-		fmt.Println("templateData class: ", class)
-	}
-	for _, class := range pfd.styleData.classes {
-		fmt.Println("styleData class: ", class)
+// Cross-compares what's used (collected from <template>...) with what exists
+// (collected from <style>... and <script>...) and returns everything that is
+// only found on one side.
+func validateComponent(c *component) []finding {
+	var pfd parsedFileData
+	var findings []finding
+
+	scan(c.template, "template", &pfd)
+	scan(c.style, "style", &pfd)
+	scan(c.script, "script", &pfd)
+
+	// Safelisted names are used by design, even if nothing seems to use them
+	var safelist []string = componentSafelist(c)
+	unused := func(section string, kind string, used []ident,
+		existing []ident) {
+		for _, id := range existing {
+			if !containsIdent(used, id.name) &&
+				!(section != "template" && safelisted(safelist, id.name)) {
+				findings = append(findings, finding{c, section, kind, id.name,
+					c.pos(section, id.offset), id.length})
+			}
+		}
 	}
-	for _, id := range pfd.templateData.ids {
-		fmt.Println("templateData ids: ", id)
+	// compare template classes/ids with style classes/ids
+	unused("style", "class", pfd.templateData.classes, pfd.styleData.classes)
+	unused("style", "id", pfd.templateData.ids, pfd.styleData.ids)
+	// compare script functions with everything that references them, be it
+	// an event handler in the template or the script itself (which includes
+	// addEventListener calls)
+	unused("script", "function",
+		append(pfd.templateData.jsRefs, pfd.scriptData.jsRefs...),
+		pfd.scriptData.jsFuncs)
+	// compare style classes/ids with template classes/ids
+	unused("template", "class", pfd.styleData.classes, pfd.templateData.classes)
+	unused("template", "id", pfd.styleData.ids, pfd.templateData.ids)
+	// compare script funtions with template functions
+	unused("template", "function", pfd.scriptData.jsFuncs,
+		pfd.templateData.jsFuncs)
+	// compare declared props with the props that the template uses
+	var props []ident
+	for _, p := range c.propDecls {
+		props = append(props, ident{p.name, p.offset,
+			utf8.RuneCountInString(p.name)})
 	}
-	for _, id := range pfd.styleData.ids {
-		fmt.Println("styleData ids: ", id)
+	unused("props", "prop", pfd.templateData.props, props)
+	unused("template", "prop", props, pfd.templateData.props)
+
+	return findings
+}
+
+// The warning printed for a finding, e.g.
+// `Found unused class "title" in style of _helloWorld`
+func (fd finding) message() string {
+	return "Found unused " + fd.kind + " \"" + fd.name + "\" in " +
+		fd.section + " of " + fd.component.name
+}
+
+// Returns the finding as a warning pointing at where it was found.
+func (fd finding) diagnostic() lib.Diagnostic {
+	return lib.Diagnostic{
+		Rule:     "unused-" + fd.kind,
+		Code:     ruleCode("unused-" + fd.kind),
+		Severity: "warning",
+		Message:  fd.message(),
+		File:     project.rel(fd.component.path),
+		Pos:      fd.pos,
+		Len:      fd.length,
+		Source:   fd.component.src,
 	}
+}
 
+// Collects the classes, ids, and JS functions of a single section of a
+// component into pfd. fileStr is expected to hold only the contents of
+// that section (see readComponent()).
+func scan(fileStr string, whichScan string, pfd *parsedFileData) {
 	if whichScan == "template" {
-		var tokens = []string{"onclick", "onmouseover", "onmouseleave",
-			"Offline", "Onabort", "onafterprint", "onbeforeonload",
-			"onbeforeprint", "onblur", "oncanplay", "oncanplaythrough",
-			"onchange", "oncontextmenu", "ondblclick", "ondrag",
-			"ondragend", "ondragenter", "ondragleave", "ondragover",
-			"ondragstart", "ondrop", "ondurationchange", "onemptied",
-			"onended", "onerror", "onfocus", "onformchange", "onforminput",
-			"onhaschange", "oninput", "oninvalid", "onkeydown",
-			"onkeypress", "onkeyup", "onload", "onloadeddata",
-			"onloadedmetadata", "onloadstart", "onmessage", "onmousedown",
-			"onmousemove", "onmouseout", "onmouseover", "onmouseup",
-			"onmousewheel", "onoffline", "ononline", "onpagehide",
-			"onpageshow", "onpause", "onplay", "onplaying", "onpopstate",
-			"onprogress", "onratechange", "onreadystatechange", "onredo",
-			"onresize", "onscroll", "onseeked", "onseeking", "onselect",
-			"onstalled", "onstorage", "onsubmit", "onsuspend",
-			"ontimeupdate", "onundo", "onunload", "onvolumechange",
-			"onwaiting"}
-		for _, token := range tokens {
-			search(fileStr, token+"=\"", "\"", &pfd.templateData.jsFuncs)
-			search(fileStr, token+"='", "'", &pfd.templateData.jsFuncs)
-		}
+		lib.ParseHTML(fileStr).Walk(func(n *lib.HTMLNode) bool {
+			if n.Type == lib.HTMLTextNode && n.Parent != nil &&
+				!n.Parent.Is("script") && !n.Parent.Is("style") {
+				for _, id := range propPlaceholders(n.Data) {
+					id.offset += n.Pos.Offset
+					pfd.templateData.props = append(pfd.templateData.props, id)
+				}
+			}
+			if n.Type != lib.HTMLElementNode {
+				return true
+			}
+			for _, attr := range n.Attrs {
+				for _, id := range propPlaceholders(attr.Raw) {
+					id.offset += attr.ValuePos.Offset
+					pfd.templateData.props = append(pfd.templateData.props, id)
+				}
+				if strings.EqualFold(attr.Name, "class") {
+					pfd.templateData.classes = append(pfd.templateData.classes,
+						attrIdents(attr)...)
+				} else if strings.EqualFold(attr.Name, "id") {
+					pfd.templateData.ids = append(pfd.templateData.ids,
+						attrIdents(attr)...)
+				}
+				if !isEventHandler(attr.Name) {
+					continue
+				}
+				// Offsets within handlers are off by a little if the
+				// handler contains character references, which is rare
+				// enough not to matter.
+				for _, ref := range lib.JSReferences(attr.Value) {
+					id := ident{ref.Name, attr.ValuePos.Offset + ref.Pos.Offset,
+						utf8.RuneCountInString(ref.Name)}
+					pfd.templateData.jsRefs = append(pfd.templateData.jsRefs, id)
+					if ref.Called && !ref.Property &&
+						!contains(browserFunctions, ref.Name) {
+						pfd.templateData.jsFuncs = append(
+							pfd.templateData.jsFuncs, id)
+					}
+				}
+			}
+			return true
+		})
+	} else if whichScan == "style" {
+		lib.WalkCSS(lib.ParseCSS(fileStr), func(rule *lib.CSSRule) {
+			for _, sel := range rule.Selectors {
+				for _, simple := range sel.SimpleSelectors() {
+					id := ident{simple.Name, simple.Pos.Offset,
+						utf8.RuneCountInString(simple.Raw)}
+					if simple.Type == lib.CSSClassSelector {
+						pfd.styleData.classes = append(pfd.styleData.classes,
+							id)
+					} else if simple.Type == lib.CSSIDSelector {
+						pfd.styleData.ids = append(pfd.styleData.ids, id)
+					}
+				}
+			}
+		})
 	} else if whichScan == "script" {
-		search(fileStr, "function ", "{", &pfd.scriptData.jsFuncs)
-		search(fileStr, "() => ", "{", &pfd.scriptData.jsFuncs)
+		for _, fn := range lib.JSFunctions(fileStr) {
+			pfd.scriptData.jsFuncs = append(pfd.scriptData.jsFuncs,
+				ident{fn.Name, fn.Pos.Offset, utf8.RuneCountInString(fn.Name)})
+		}
+		for _, ref := range lib.JSReferences(fileStr) {
+			pfd.scriptData.jsRefs = append(pfd.scriptData.jsRefs,
+				ident{ref.Name, ref.Pos.Offset, utf8.RuneCountInString(ref.Name)})
+		}
 	}
-	for _, jsFunc := range pfd.templateData.jsFuncs {
-		fmt.Println("jsFunc: ", jsFunc)
+}
+
+// Returns each whitespace-separated name in the value of attr (as in a class
+// attribute), along with the offset it starts at.
+func attrIdents(attr *lib.HTMLAttr) []ident {
+	var idents []ident
+	var start int = -1
+	// Props, as in class="card {{ kind }}", aren't known until the build
+	var raw string = propPlaceholder.ReplaceAllStringFunc(attr.Raw,
+		func(placeholder string) string {
+			return strings.Repeat(" ", len(placeholder))
+		})
+	for i := 0; i <= len(raw); i++ {
+		if i < len(raw) && !strings.ContainsRune(" \t\r\n\f", rune(raw[i])) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			name := html.UnescapeString(raw[start:i])
+			idents = append(idents, ident{name, attr.ValuePos.Offset + start,
+				utf8.RuneCountInString(raw[start:i])})
+			start = -1
+		}
 	}
-	for _, jsFunc := range pfd.scriptData.jsFuncs {
-		fmt.Println("jsFunc: ", jsFunc)
+	return idents
+}
+
+// Returns whether any of idents is called name.
+func containsIdent(idents []ident, name string) bool {
+	for _, id := range idents {
+		if id.name == name {
+			return true
+		}
 	}
+	return false
 }
 
-func search(searchParameter string, startSubstr string, endSubstr string,
-	arr *[]string) int {
-	var resultsFound int = 0
-	var className string
-	var startIdx, endIdx int
-
-	startIdx = strings.Index(searchParameter, startSubstr)
-	// Ensure we're basing search on not-searched data
-	if startIdx == -1 {
-		return 0
-	}
-	endIdx = strings.Index(searchParameter[startIdx+len(startSubstr):], endSubstr)
-
-	// Do search for classes via [class=", "]
-	for startIdx != -1 && endIdx != -1 {
-		// Store class name of previously parsed info
-		className = searchParameter[startIdx+len(startSubstr) : startIdx+len(startSubstr)+endIdx]
-		*arr = append(*arr, strings.TrimSpace(className))
-		resultsFound++
-		// update search parameter
-		searchParameter = searchParameter[startIdx+len(startSubstr)+endIdx+len(endSubstr):]
-		// restart search for new clas
-		startIdx = strings.Index(searchParameter, startSubstr)
-		if startIdx == len(searchParameter) ||
-			startIdx == len(searchParameter)-1 {
-			return resultsFound
-		}
-		// Ensure we're basing search on not-searched data
-		endIdx = strings.Index(searchParameter[startIdx+len(startSubstr):], endSubstr)
-	}
-	return resultsFound
+// Functions that browsers provide, which event handlers can call without the
+// component's script defining them.
+var browserFunctions = []string{"alert", "confirm", "prompt", "setTimeout",
+	"setInterval", "clearTimeout", "clearInterval", "requestAnimationFrame",
+	"fetch", "parseInt", "parseFloat", "isNaN", "encodeURIComponent",
+	"decodeURIComponent", "Number", "String", "Boolean", "open", "close",
+	"print", "scrollTo", "focus", "blur"}
+
+// Returns whether the attribute called name is an event handler, such as
+// onclick or onmouseover.
+func isEventHandler(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(strings.ToLower(name), "on")
 }
 
-func makeProjectTree() {
+func makeProjectTree() error {
 	// Store all of the paths we want to create in the PWD that the command
 	// `webes init` is called in.
-	var paths = [9]string{
-		"dist/imgs", "dist/scripts", "dist/styles", "dist/pages", "dev/imgs",
-		"dev/pages", "dev/components", "dev/styles", "dev/scripts",
+	var paths = [10]string{
+		distPath("imgs"), distPath("scripts"), distPath("styles"),
+		distPath("pages"), devPath("imgs"), devPath("pages"),
+		devPath("components"), devPath("layouts"), devPath("styles"),
+		devPath("scripts"),
 	}
 
 	// For each specified path, attempt to create the full directory path,
-	// and if there's an error, panic.
+	// and if there's an error, stop.
 	for _, path := range paths {
-		err := os.MkdirAll(pwd+path, 0755)
+		err := os.MkdirAll(path, 0755)
 		if err != nil {
-			panic(err)
+			return writeError(path, err)
 		}
 	}
 
 	// Store all of the files that we want to create in the PWD
-	var files = [4]fileT{
+	var files = [5]fileT{
 		{
-			path: "dist/",
+			// Every page in dev/pages that doesn't have a layout of its own
+			// ends up within this one, so the <head> is only written once
+			path: devPath("layouts"),
+			name: "default.webes",
+			content: "<template>\n<!DOCTYPE html>\n<html lang='en-us'>\n" +
+				"<head>\n	<!--Metadata (the title, description and the " +
+				"like come from each page's front matter)-->\n	<meta " +
+				"charset='UTF-8'>\n	<meta name='viewport' " +
+				"content='width=device-width, initial-scale=1'>\n	<meta " +
+				"name='robots' content='index,follow'>\n	<meta " +
+				"property='og:type' content='website'/>\n\n	" +
+				"<!--Dependencies-->\n	<link rel='stylesheet' " +
+				"href='/styles/style.css'>\n	<slot name='head'></slot>\n" +
+				"</head>\n<body>\n	<slot></slot>\n\n	<!--Non-Critical " +
+				"Dependencies-->\n	<script src='/scripts/script.js'></script>" +
+				"\n</body>\n</html>\n</template>\n#end",
+		},
+		{
+			path: devPath("pages"),
 			name: "index.html",
-			content: "<!DOCTYPE HTML>\n<html lang='en-us'>" +
-				"</html>\n<head>\n	<title></title>\n	<!--Metadata-->\n	<" +
-				"meta charset='UTF-8'>\n	<meta name='viewport' content='wi" +
-				"dth=device-width, initial-scale=1'>\n	<meta name=robots con" +
-				"tent='index,follow'>\n	<meta name='description' content='!WE" +
-				"BSITE_DESCRIPTION'>\n	<meta name='keywords' content='!RELEV" +
-				"ANT_KEYWORDS'>\n	<meta name='author' content='!AUTHOR_NAME" +
-				"'>\n	<meta rel='canonical' href='!YOUR_URL'>\n	<meta nam" +
-				"e='subject' content='!WEBSITE_SUBJECT'>\n	<meta name='url' " +
-				"content='!YOUR_URL'>\n	<meta http-equiv='Expires' content='0" +
-				"'>\n	<meta http-equiv='imagetoolbar' content='no'>\n\n	<" +
-				"!--Twitter Card Specs-->\n	<meta name='twitter:card' content" +
-				"='summary'>\n	<meta name='twitter:site' content='@!YOUR_TWI" +
-				"TTER_HANDLE'>\n	<meta name='twitter:title' content='!YOUR" +
-				"_TITLE'>\n	<meta name='twitter:description' content='!YOUR_D" +
-				"ESCRIPTION'>\n	<meta name='twitter:image' content='!PATH_FOR" +
-				"_DISPLAY_IMAGE'>\n\n	<!--Open Graph Specs-->\n	<meta pro" +
-				"perty='og:title' content='!YOUR_TITLE'/>\n	<meta property='o" +
-				"g:type' content='article'/>\n	<meta property='og:url' conte" +
-				"nt='!YOUR_URL'/>\n	<meta property='og:image' content='!PATH_" +
-				"FOR_DISPLAY_IMAGE'/>\n	<meta property='og:description' conte" +
-				"nt='!YOUR_DESCRIPTION'/>\n	<meta property='og:site_name' con" +
-				"tent='!YOUR_WEBSITE_NAME'/>\n\n	<!--Dependencies-->\n	<" +
-				"link rel='stylesheet' href='styles/style.css'>\n</head>\n<bo" +
-				"dy>\n	\n\t<!--Non-Critical Dependencies-->\n	<script langu" +
-				"age=\"javascript\" type=\"text/javascript\" src=\"scripts/sc" +
-				"ript.js\"></script>\n</body>\n</html>",
+			content: "---\ntitle: Hello, World!\ndescription: A website " +
+				"made with webes\n---\n<HelloWorld />\n",
 		},
 		{
-			path: "dev/styles/",
+			path: devPath("styles"),
 			name: "style.css",
 			content: "html,body {\n	margin:0;\n	background-color:#333;\n	" +
 				"color:white;\n}\n",
 		},
 		{
-			path: "dev/components/",
+			path: devPath("components"),
 			name: "_helloWorld.webes",
 			content: "<template>\n	<div id='title' class='_helloWorld'>\n" +
 				"		<h1>Hello, World!</h1>\n	</div>\n</template>\n\n\n" +
@@ -513,43 +736,159 @@ func makeProjectTree() {
 				"</script>\n#end",
 		},
 		{
-			path:    "dev/scripts/",
+			path:    devPath("scripts"),
 			name:    "script.js",
 			content: "console.log('Hello World!');\n",
 		},
 	}
 	// For each file to be created, attempt to create said file with the
-	// specified file data. If there's an error in this process, panic.
+	// specified file data. If there's an error in this process, stop.
 	for _, file := range files {
 		fileData := []byte(file.content)
-		err := os.WriteFile(pwd+file.path+file.name, fileData, 0644)
+		var path string = filepath.Join(file.path, file.name)
+		err := os.WriteFile(path, fileData, 0644)
 		if err != nil {
-			panic(err)
+			return writeError(path, err)
 		}
 	}
+	// webes.json is left alone if it's already there, as it decides where
+	// dev/ and dist/ went
+	if err := writeDefaultConfig(); err != nil {
+		return writeError(filepath.Join(project.root, configFile), err)
+	}
+	return nil
 }
 
 // Function automatically ran during webes launch that ensures the
 // commands map contains all of the commands that the user can execute.
 func runCommandInitializtion() {
+	commands["build"] = Command{
+		function: webes_build,
+		description: "Compiles the pages in dev/ into a static site in " +
+			"dist/.",
+		flags: []commandFlag{
+			{name: "watch", value: false, description: "keep rebuilding " +
+				"whatever is affected by changes to dev/"},
+			{name: "page", value: "", description: "only build this page, " +
+				"given relative to dev/pages", complete: completePages},
+		},
+		examples: []string{"webes build", "webes build --watch",
+			"webes build --page=blog/post.md"},
+		needsProject: true,
+	}
 	commands["boilerplate"] = Command{
 		function:    webes_boilerplate,
 		description: "Creates a new boilerplate HTML file in PWD",
+		args: []commandArg{
+			{name: "name", description: "the name of the file, which is " +
+				"asked for if it's left out (.html is added if missing)",
+				optional: true},
+		},
+		examples:   []string{"webes boilerplate about"},
+		standalone: true,
+	}
+	commands["completion"] = Command{
+		function: webes_completion,
+		description: "Prints a script that completes webes commands, flags, " +
+			"components and pages in your shell.",
+		args: []commandArg{
+			{name: "shell", description: "one of: " +
+				strings.Join(completionShells(), ", "),
+				complete: completionShells},
+		},
+		examples: []string{"source <(webes completion bash)",
+			"webes completion zsh > \"${fpath[1]}/_webes\"",
+			"webes completion fish > ~/.config/fish/completions/webes.fish"},
+		standalone: true,
+	}
+	commands["__complete"] = Command{
+		function:    webes_complete,
+		description: "Prints what the last word can be completed to.",
+		args: []commandArg{
+			{name: "words", optional: true, variadic: true},
+		},
+		standalone: true,
+		hidden:     true,
 	}
 	commands["init"] = Command{
 		function:    webes_init,
 		description: "Initializes a new webes project",
+		examples:    []string{"webes init"},
+	}
+	commands["explain"] = Command{
+		function: webes_explain,
+		description: "Explains a diagnostic code (e.g. W101), along with " +
+			"how to fix what it reports.",
+		args: []commandArg{
+			{name: "code", description: "the code, as shown next to a " +
+				"warning or error, or leave it out to list every code",
+				optional: true, complete: codeNames},
+		},
+		examples:   []string{"webes explain", "webes explain E201"},
+		standalone: true,
+	}
+	commands["graph"] = Command{
+		function: webes_graph,
+		description: "Prints which pages and components include which " +
+			"components.",
+		flags: []commandFlag{
+			{name: "format", value: "tree", description: "how the graph " +
+				"is printed", choices: []string{"tree", "dot", "json"}},
+		},
+		examples: []string{"webes graph",
+			"webes graph --format=dot | dot -Tsvg > graph.svg"},
+		needsProject: true,
 	}
 	commands["help"] = Command{
 		function:    webes_help,
 		description: "Provides details about the various webes commands",
+		args: []commandArg{
+			{name: "command", description: "the command to describe in " +
+				"full", optional: true, complete: commandNames},
+		},
+		examples:   []string{"webes help", "webes help validate"},
+		standalone: true,
+	}
+	commands["serve"] = Command{
+		function: webes_serve,
+		description: "Serves dist/ locally, rebuilding and reloading the " +
+			"browser whenever dev/ changes.",
+		flags: []commandFlag{
+			{name: "host", value: "localhost",
+				description: "the host to listen on"},
+			{name: "port", value: 8080, description: "the port to listen on"},
+		},
+		examples:     []string{"webes serve", "webes serve --port=3000"},
+		needsProject: true,
 	}
 	commands["validate"] = Command{
-		function:    webes_validate,
-		description: "(WIP) Checks dev/ for unused assets/code-segments.",
+		function: webes_validate,
+		description: "Checks dev/ for unused assets, selectors, functions " +
+			"and props (W1xx warnings) and for components and pages that " +
+			"wouldn't build (E2xx/E3xx errors).",
+		flags: []commandFlag{
+			{name: "format", value: "text", description: "how diagnostics " +
+				"are printed", choices: []string{"text", "json", "sarif"}},
+		},
+		examples: []string{"webes validate",
+			"webes validate --format=sarif > webes.sarif"},
+		needsProject: true,
+	}
+	commands["why"] = Command{
+		function:    webes_why,
+		description: "Lists every page that ends up using a component.",
+		args: []commandArg{
+			{name: "component", description: "the component, e.g. " +
+				"_helloWorld, HelloWorld or hello-world",
+				complete: completeComponents},
+		},
+		examples:     []string{"webes why HelloWorld"},
+		needsProject: true,
 	}
 	commands["wipe"] = Command{
-		function:    webes_wipe,
-		description: "Deletes the webes project that exists within the PWD.",
+		function:     webes_wipe,
+		description:  "Deletes the webes project that the PWD is within.",
+		examples:     []string{"webes wipe"},
+		needsProject: true,
 	}
 }
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"html"          // Used for escaping the title
+	"path/filepath" // Used for building URLs from paths
+	"regexp"        // Used for finding placeholders
+	"strings"       // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// Pages (.html and .md alike) can start with front matter, which the build
+// turns into the page's <title> along with its description, canonical link,
+// Twitter Card and Open Graph tags:
+//
+//	---
+//	title: Hello, World!
+//	description: The first page of my website
+//	image: /imgs/card.png
+//	canonical: https://example.com/
+//	---
+//	<h1>Hello, World!</h1>
+//
+// Tags that the page's <head> already has (e.g. from its layout) are filled
+// in, and the others are added to the end of it.
+
+// The front matter of a page.
+type frontMatter struct {
+	title       string
+	description string
+	keywords    string
+	image       string
+	canonical   string
+	robots      string
+	ogType      string
+	layout      string // the layout the page goes in, instead of the default
+	layoutLine  int    // the line that layout was given on
+	given       bool   // whether the page has front matter at all
+
+	// Filled in from webes.json
+	siteName string
+	author   string
+	twitter  string
+}
+
+// The keys that front matter accepts.
+var frontMatterKeys = []string{"title", "description", "keywords", "image",
+	"canonical", "robots", "og:type", "layout"}
+
+// Reads the front matter at the start of src, returning it along with the
+// offset that the rest of src starts at (0 if src has no front matter).
+func parseFrontMatter(src string) (frontMatter, int, error) {
+	var meta frontMatter
+	fields, start, err := lib.ParseFrontMatter(src)
+	if err != nil {
+		return meta, 0, err
+	}
+
+	meta.given = len(fields) > 0
+	var seen []string
+	for _, field := range fields {
+		var key string = strings.ToLower(field.Key)
+		if !contains(frontMatterKeys, key) {
+			return meta, 0, newError("E207", "unknown key \"%s\" on line %d of "+
+				"the front matter, expected one of: %s", field.Key,
+				field.Line, strings.Join(frontMatterKeys, ", "))
+		}
+		if contains(seen, key) {
+			return meta, 0, newError("E207", "key \"%s\" is given a second "+
+				"time on line %d of the front matter", field.Key, field.Line)
+		}
+		seen = append(seen, key)
+
+		switch key {
+		case "title":
+			meta.title = field.Value
+		case "description":
+			meta.description = field.Value
+		case "keywords":
+			meta.keywords = keywordList(field.Value)
+		case "image":
+			meta.image = field.Value
+		case "canonical":
+			meta.canonical = field.Value
+		case "robots":
+			meta.robots = field.Value
+		case "og:type":
+			meta.ogType = field.Value
+		case "layout":
+			meta.layout, meta.layoutLine = field.Value, field.Line
+		}
+	}
+	return meta, start, nil
+}
+
+// Returns meta with what the page's front matter leaves out filled in from
+// the site's settings in webes.json, for the page p. Pages are given a
+// canonical link based on the site's URL, and images given relative to the
+// site's root are made absolute, as social networks require them to be.
+func (meta frontMatter) withSite(p page) frontMatter {
+	var site siteConfig = project.config.Site
+	meta.siteName, meta.author, meta.twitter = site.Name, site.Author,
+		site.Twitter
+	if meta.description == "" {
+		meta.description = site.Description
+	}
+	if site.URL == "" {
+		return meta
+	}
+	if meta.canonical == "" {
+		if rel, err := filepath.Rel(distPath(), p.dest); err == nil {
+			meta.canonical = site.URL + "/" +
+				strings.TrimSuffix(filepath.ToSlash(rel), "index.html")
+		}
+	}
+	if strings.HasPrefix(meta.image, "/") &&
+		!strings.HasPrefix(meta.image, "//") {
+		meta.image = site.URL + meta.image
+	}
+	return meta
+}
+
+// Returns keywords, given as either `a, b` or `[a, "b"]`, as `a, b`.
+func keywordList(keywords string) string {
+	keywords = strings.TrimSpace(keywords)
+	if strings.HasPrefix(keywords, "[") && strings.HasSuffix(keywords, "]") {
+		keywords = keywords[1 : len(keywords)-1]
+	}
+	var list []string
+	for _, keyword := range strings.Split(keywords, ",") {
+		keyword = strings.Trim(strings.TrimSpace(keyword), "\"'")
+		if keyword != "" {
+			list = append(list, keyword)
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// A tag within <head> that front matter fills in.
+type headTag struct {
+	name  string // "title", "meta" or "link"
+	key   string // the attribute identifying the tag, e.g. "name" or "rel"
+	id    string // the value of key, e.g. "description"
+	attr  string // the attribute holding the value, "" for <title>
+	value string
+}
+
+// Returns the tags that meta fills in, leaving out those it has no value for.
+func (meta frontMatter) headTags() []headTag {
+	var tags []headTag
+	add := func(name string, key string, id string, attr string,
+		value string) {
+		if value != "" {
+			tags = append(tags, headTag{name, key, id, attr, value})
+		}
+	}
+
+	add("title", "", "", "", meta.title)
+	add("meta", "name", "description", "content", meta.description)
+	add("meta", "name", "keywords", "content", meta.keywords)
+	add("meta", "name", "robots", "content", meta.robots)
+	add("meta", "name", "author", "content", meta.author)
+	add("link", "rel", "canonical", "href", meta.canonical)
+
+	if meta.title != "" || meta.description != "" || meta.image != "" ||
+		meta.twitter != "" {
+		var card string = "summary"
+		if meta.image != "" {
+			card = "summary_large_image"
+		}
+		add("meta", "name", "twitter:card", "content", card)
+	}
+	add("meta", "name", "twitter:site", "content", meta.twitter)
+	add("meta", "name", "twitter:title", "content", meta.title)
+	add("meta", "name", "twitter:description", "content", meta.description)
+	add("meta", "name", "twitter:image", "content", meta.image)
+
+	add("meta", "property", "og:title", "content", meta.title)
+	add("meta", "property", "og:type", "content", meta.ogType)
+	add("meta", "property", "og:url", "content", meta.canonical)
+	add("meta", "property", "og:image", "content", meta.image)
+	add("meta", "property", "og:description", "content", meta.description)
+	add("meta", "property", "og:site_name", "content", meta.siteName)
+	return tags
+}
+
+// Fills in the tags within the <head> of doc that meta has values for, adding
+// the ones that aren't there yet.
+func applyFrontMatter(doc *lib.HTMLNode, meta frontMatter) error {
+	var tags []headTag = meta.headTags()
+	if len(tags) == 0 {
+		return nil
+	}
+	head, ok := doc.Find("head")
+	// What comes from webes.json only goes into pages that have a <head>
+	if !ok && !meta.given {
+		return nil
+	}
+	if !ok {
+		return newError("E208", "the page has front matter but no <head> to "+
+			"put it in, as it has no layout")
+	}
+
+	for _, tag := range tags {
+		var el *lib.HTMLNode = findHeadTag(head, tag)
+		if el == nil {
+			el = &lib.HTMLNode{Type: lib.HTMLElementNode, Name: tag.name,
+				EndTag: tag.name == "title"}
+			if tag.key != "" {
+				el.SetAttr(tag.key, tag.id)
+			}
+			head.AppendChild(&lib.HTMLNode{Type: lib.HTMLTextNode, Data: "\t"})
+			head.AppendChild(el)
+			head.AppendChild(&lib.HTMLNode{Type: lib.HTMLTextNode, Data: "\n"})
+		}
+		if tag.attr != "" {
+			el.SetAttr(tag.attr, tag.value)
+			continue
+		}
+		el.Children = nil
+		el.AppendChild(&lib.HTMLNode{Type: lib.HTMLTextNode,
+			Data: html.EscapeString(tag.value)})
+	}
+	return nil
+}
+
+// Returns the element within head that tag would fill in, or nil if there's
+// none. Open Graph tags are found whether they use name or property.
+func findHeadTag(head *lib.HTMLNode, tag headTag) *lib.HTMLNode {
+	var found *lib.HTMLNode
+	head.Walk(func(n *lib.HTMLNode) bool {
+		if found != nil || !n.Is(tag.name) {
+			return found == nil
+		}
+		if tag.key == "" || strings.EqualFold(n.AttrValue(tag.key), tag.id) ||
+			tag.name == "meta" &&
+				(strings.EqualFold(n.AttrValue("name"), tag.id) ||
+					strings.EqualFold(n.AttrValue("property"), tag.id)) {
+			found = n
+		}
+		return found == nil
+	})
+	return found
+}
+
+// Matches placeholders such as !YOUR_URL, which boilerplate used to be full
+// of. Markup like <!DOCTYPE html> isn't one.
+var placeholderPattern = regexp.MustCompile(
+	`(?:^|[^<\w])(![A-Z][A-Z0-9_]{2,})\b`)
+
+// Returns the first placeholder that's left within doc, outside of scripts
+// and styles, along with what it was found in, e.g. "the content of <meta>".
+func findPlaceholder(doc *lib.HTMLNode) (string, string, bool) {
+	var placeholder, where string
+	doc.Walk(func(n *lib.HTMLNode) bool {
+		if placeholder != "" || n.IsRawText() {
+			return false
+		}
+		if n.Type == lib.HTMLTextNode {
+			if m := placeholderPattern.FindStringSubmatch(n.Data); m != nil {
+				placeholder = m[1]
+				where = "the text of the page"
+				if n.Parent != nil && n.Parent.Type == lib.HTMLElementNode {
+					where = "the text of <" + n.Parent.Name + ">"
+				}
+			}
+			return false
+		}
+		if n.Type != lib.HTMLElementNode {
+			return true
+		}
+		for _, attr := range n.Attrs {
+			if m := placeholderPattern.FindStringSubmatch(attr.Value); m != nil {
+				placeholder = m[1]
+				where = "the " + attr.Name + " of <" + n.Name + ">"
+				return false
+			}
+		}
+		return true
+	})
+	return placeholder, where, placeholder != ""
+}
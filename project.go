@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"            // Used for looking for the project's markers
+	"path/filepath" // Used for building file paths
+	"strings"       // Used for string manipulation
+)
+
+// A webes project: the directory holding webes.json (or dev/ and dist/),
+// along with its settings. Every path is absolute, so that commands work the
+// same from anywhere within the project.
+type Project struct {
+	root   string
+	dev    string
+	dist   string
+	config projectConfig
+}
+
+// The project that the command at hand works on, as opened by
+// commandHandler().
+var project = &Project{config: defaultConfig()}
+
+// Finds the project that dir is within, walking up from dir until a directory
+// holds webes.json, or dev/ along with dist/.
+func findProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if isProjectRoot(dir) {
+			return openProject(dir)
+		}
+		var parent string = filepath.Dir(dir)
+		if parent == dir {
+			return nil, newError("E303", "not within a webes project, as "+
+				"neither this directory nor any above it holds %s (or dev/ "+
+				"and dist/), run `webes init` to create one", configFile)
+		}
+		dir = parent
+	}
+}
+
+// Returns whether dir is the root of a project.
+func isProjectRoot(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, configFile)); err == nil &&
+		!info.IsDir() {
+		return true
+	}
+	for _, sub := range []string{"dev", "dist"} {
+		info, err := os.Stat(filepath.Join(dir, sub))
+		if err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// Opens the project whose root is dir, loading its webes.json (if it has
+// one).
+func openProject(dir string) (*Project, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(root, path)
+	}
+	return &Project{root: root, dev: resolve(cfg.Dirs.Dev),
+		dist: resolve(cfg.Dirs.Dist), config: cfg}, nil
+}
+
+// Returns path (within the project) relative to the project's root, as it's
+// shown to the user, e.g. "dev/pages/index.html".
+func (p *Project) rel(path string) string {
+	rel, err := filepath.Rel(p.root, path)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
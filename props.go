@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"          // Used for building errors
+	"html"         // Used for escaping prop values
+	"regexp"       // Used for finding {{ name }} placeholders
+	"strconv"      // Used for checking number props
+	"strings"      // Used for string manipulation
+	"unicode/utf8" // Used for measuring attribute values
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// A prop that a component declares in its <props> block, one per line:
+//
+//	<props>
+//		title: string = "Hello, World!"
+//		count: number = 0
+//		subtitle?: string
+//		large: boolean
+//	</props>
+//
+// Props without a default are required, unless their name ends in "?".
+type prop struct {
+	name     string
+	kind     string // "string", "number" or "boolean"
+	def      string // the default value, for props that aren't required
+	required bool
+	offset   int // where the declaration starts within the props section
+}
+
+// The types that props can be declared with.
+var propKinds = []string{"string", "number", "boolean"}
+
+// Matches a {{ name }} placeholder, capturing the name.
+var propPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_$][\w$-]*)\s*\}\}`)
+
+// Parses the <props> block of c into c.propDecls. Errors point at the
+// declaration that couldn't be parsed.
+func parseProps(c *component) error {
+	var offset int = 0
+	for _, line := range strings.SplitAfter(c.props, "\n") {
+		var start int = offset
+		offset += len(line)
+		var decl string = strings.TrimSpace(line)
+		if decl == "" {
+			continue
+		}
+		start += strings.Index(line, decl)
+		fail := func(format string, args ...interface{}) error {
+			e := newError("E205", format, args...)
+			e.File, e.Pos, e.Len = project.rel(c.path), c.pos("props", start),
+				len([]rune(decl))
+			e.Source = c.src
+			return e
+		}
+
+		colon := strings.Index(decl, ":")
+		if colon == -1 {
+			return fail("prop declaration %q should look like "+
+				"`name: type = default`", decl)
+		}
+		var p = prop{name: strings.TrimSpace(decl[:colon]), offset: start,
+			required: true}
+		var rest string = strings.TrimSpace(decl[colon+1:])
+		if strings.HasSuffix(p.name, "?") {
+			p.name = strings.TrimSpace(strings.TrimSuffix(p.name, "?"))
+			p.required = false
+		}
+		if eq := strings.Index(rest, "="); eq != -1 {
+			p.def = strings.TrimSpace(rest[eq+1:])
+			if unquoted, err := strconv.Unquote(p.def); err == nil {
+				p.def = unquoted
+			}
+			rest = strings.TrimSpace(rest[:eq])
+			p.required = false
+		} else if !p.required && rest == "boolean" {
+			p.def = "false"
+		}
+		p.kind = rest
+
+		if !propPlaceholder.MatchString("{{" + p.name + "}}") {
+			return fail("%q isn't a valid prop name", p.name)
+		}
+		if strings.EqualFold(p.name, "slot") {
+			return fail("\"slot\" is reserved for filling slots, it can't " +
+				"be a prop")
+		}
+		if !contains(propKinds, p.kind) {
+			return fail("prop %q has unknown type %q, expected one of: %s",
+				p.name, p.kind, strings.Join(propKinds, ", "))
+		}
+		if !p.required && p.def != "" && !propValueValid(p, p.def) {
+			return fail("default of prop %q isn't a %s", p.name, p.kind)
+		}
+		for _, other := range c.propDecls {
+			if strings.EqualFold(other.name, p.name) {
+				return fail("prop %q is declared more than once", p.name)
+			}
+		}
+		c.propDecls = append(c.propDecls, p)
+	}
+	return nil
+}
+
+// Returns whether value can be given to p.
+func propValueValid(p prop, value string) bool {
+	switch p.kind {
+	case "number":
+		_, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil
+	case "boolean":
+		return value == "true" || value == "false"
+	}
+	return true
+}
+
+// Returns the value of each of c's props as given by el, the element that
+// uses c, along with every problem with them: unknown props, missing required
+// props, and values of the wrong type.
+func propValues(c *component, el *lib.HTMLNode) (map[string]string,
+	[]usageProblem) {
+	var values = make(map[string]string)
+	var problems []usageProblem
+
+	for _, attr := range el.Attrs {
+		// The src of <webes-include> names the component, it isn't a prop,
+		// and neither is the name of <webes-layout>
+		if el.Is("webes-include") && strings.EqualFold(attr.Name, "src") ||
+			el.Is("webes-layout") && strings.EqualFold(attr.Name, "name") {
+			continue
+		}
+		// Neither is the slot that el fills within the component around it
+		if strings.EqualFold(attr.Name, "slot") {
+			continue
+		}
+		p, ok := c.prop(attr.Name)
+		if !ok {
+			problems = append(problems, usageProblem{attr.Pos, len(attr.Name),
+				fmt.Sprintf("%s has no prop called %q", c.name, attr.Name),
+				"prop-error", "error"})
+			continue
+		}
+		var value string = attr.Value
+		if p.kind == "boolean" && !attr.HasValue {
+			// <Card large /> is the same as <Card large="true" />
+			value = "true"
+		}
+		// Values passed on from the props of the component using c, as in
+		// <Card title="{{ title }}" />, are only known once it's expanded
+		if !propValueValid(p, value) && !propPlaceholder.MatchString(value) {
+			problems = append(problems, usageProblem{attr.ValuePos,
+				utf8.RuneCountInString(attr.Raw),
+				fmt.Sprintf("prop %q of %s expects a %s, got %q", p.name,
+					c.name, p.kind, value), "prop-error", "error"})
+			continue
+		}
+		values[p.name] = value
+	}
+
+	for _, p := range c.propDecls {
+		if _, ok := values[p.name]; ok {
+			continue
+		}
+		if _, given := el.Attr(p.name); given {
+			continue
+		}
+		if p.required {
+			problems = append(problems, usageProblem{el.Pos,
+				len("<" + el.Name), fmt.Sprintf("%s is missing required prop %q",
+					c.name, p.name), "prop-error", "error"})
+			continue
+		}
+		values[p.name] = p.def
+	}
+	return values, problems
+}
+
+// Returns the prop of c called name, ignoring case (as attribute names do).
+func (c *component) prop(name string) (prop, bool) {
+	for _, p := range c.propDecls {
+		if strings.EqualFold(p.name, name) {
+			return p, true
+		}
+	}
+	return prop{}, false
+}
+
+// Replaces every {{ name }} placeholder within the text and attribute values
+// of template with the value of the prop called name. Placeholders of props
+// that aren't in values are left alone.
+func substituteProps(template *lib.HTMLNode, values map[string]string) {
+	replace := func(text string, escape bool) string {
+		return propPlaceholder.ReplaceAllStringFunc(text,
+			func(placeholder string) string {
+				name := propPlaceholder.FindStringSubmatch(placeholder)[1]
+				value, ok := values[name]
+				if !ok {
+					return placeholder
+				}
+				if escape {
+					return html.EscapeString(value)
+				}
+				return value
+			})
+	}
+
+	template.Walk(func(n *lib.HTMLNode) bool {
+		switch n.Type {
+		case lib.HTMLTextNode:
+			if n.Parent == nil ||
+				!n.Parent.Is("script") && !n.Parent.Is("style") {
+				n.Data = replace(n.Data, true)
+			}
+		case lib.HTMLElementNode:
+			for _, attr := range n.Attrs {
+				if value := replace(attr.Value, false); value != attr.Value {
+					attr.SetValue(value)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// Returns the name of every {{ name }} placeholder within text, along with
+// the offset it starts at.
+func propPlaceholders(text string) []ident {
+	var idents []ident
+	for _, m := range propPlaceholder.FindAllStringSubmatchIndex(text, -1) {
+		idents = append(idents, ident{text[m[2]:m[3]], m[2], m[3] - m[2]})
+	}
+	return idents
+}
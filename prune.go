@@ -0,0 +1,208 @@
+package main
+
+import (
+	"os"            // Used for reading referenced scripts
+	"path/filepath" // Used for building file paths
+	"strings"       // Used for string manipulation
+	"unicode"       // Used for telling words apart
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// Returns the names listed by the `webes-safelist:` comments in c's style and
+// script, e.g. `/* webes-safelist: is-open is-active */`, along with those
+// that webes.json safelists for every component. Classes, ids, and
+// functions on the list are never left out of a build, which is needed for
+// the likes of classes that are only ever added by a script. Names ending in
+// "*" match every name starting with what comes before it.
+func componentSafelist(c *component) []string {
+	var safelist = append([]string{}, project.config.Build.Safelist...)
+	for _, section := range []string{c.style, c.script} {
+		for {
+			idx := strings.Index(section, "webes-safelist:")
+			if idx == -1 {
+				break
+			}
+			section = section[idx+len("webes-safelist:"):]
+			var end int = len(section)
+			if e := strings.IndexAny(section, "\n"); e != -1 {
+				end = e
+			}
+			if e := strings.Index(section, "*/"); e != -1 && e < end {
+				end = e
+			}
+			safelist = append(safelist, strings.FieldsFunc(section[:end],
+				func(r rune) bool {
+					return r == ',' || r == ' ' || r == '\t' || r == '\r'
+				})...)
+			section = section[end:]
+		}
+	}
+	return safelist
+}
+
+// Returns whether name is on safelist (see componentSafelist()).
+func safelisted(safelist []string, name string) bool {
+	for _, entry := range safelist {
+		entry = strings.TrimLeft(entry, ".#")
+		if entry == name || strings.HasSuffix(entry, "*") &&
+			strings.HasPrefix(name, strings.TrimSuffix(entry, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the (already scoped) style without the selectors that match no
+// element in any of docs, along with how many were removed. Rules left
+// without any selectors are removed entirely, as are the @media and the like
+// that end up empty.
+func pruneStyle(style string, docs []*lib.HTMLNode,
+	safelist []string) (string, int) {
+	rules, removed := pruneRules(lib.ParseCSS(style), docs, safelist)
+	if removed == 0 {
+		return style, 0
+	}
+	return lib.RenderCSS(rules), removed
+}
+
+func pruneRules(rules []*lib.CSSRule, docs []*lib.HTMLNode,
+	safelist []string) ([]*lib.CSSRule, int) {
+	var kept []*lib.CSSRule
+	var removed int = 0
+	for _, rule := range rules {
+		if rule.IsKeyframes() {
+			kept = append(kept, rule)
+			continue
+		}
+		if rule.Name != "" {
+			if len(rule.Rules) > 0 {
+				var n int
+				rule.Rules, n = pruneRules(rule.Rules, docs, safelist)
+				removed += n
+				if len(rule.Rules) == 0 {
+					continue
+				}
+			}
+			kept = append(kept, rule)
+			continue
+		}
+
+		var selectors []*lib.CSSSelector
+		for _, sel := range rule.Selectors {
+			if selectorUsed(sel, docs, safelist) {
+				selectors = append(selectors, sel)
+			} else {
+				removed++
+			}
+		}
+		if len(rule.Selectors) > 0 && len(selectors) == 0 {
+			continue
+		}
+		rule.Selectors = selectors
+		kept = append(kept, rule)
+	}
+	return kept, removed
+}
+
+// Returns whether sel matches an element in any of docs, or names a
+// safelisted class or id.
+func selectorUsed(sel *lib.CSSSelector, docs []*lib.HTMLNode,
+	safelist []string) bool {
+	for _, simple := range sel.SimpleSelectors() {
+		if (simple.Type == lib.CSSClassSelector ||
+			simple.Type == lib.CSSIDSelector) &&
+			safelisted(safelist, simple.Name) {
+			return true
+		}
+	}
+	for _, doc := range docs {
+		if lib.SelectorMatchesAny(doc, sel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the script of every component in used without the top-level
+// functions that nothing refers to, along with how many functions were
+// removed. To stay on the safe side, a function is kept if its name shows up
+// anywhere other than its declaration: in the page sources, in the scripts
+// (relative to dist/) within assets, or in any part of the components
+// themselves, even if that's within a string or a comment. This is repeated
+// until there's nothing left to remove, as removing one function can leave
+// another one unused.
+func pruneScripts(used []*component, pages []string,
+	assets []string) (map[*component]string, int) {
+	var pageWords = make(map[string]int)
+	for _, page := range pages {
+		countWords(pageWords, page)
+	}
+	for _, asset := range assets {
+		if filepath.Ext(asset) != ".js" {
+			continue
+		}
+		data, err := os.ReadFile(devPath(filepath.FromSlash(asset)))
+		if err == nil {
+			countWords(pageWords, string(data))
+		}
+	}
+	// Everything of the components but their scripts, which change as
+	// functions are removed
+	for _, c := range used {
+		var start int = c.offsets["script"]
+		countWords(pageWords, c.src[:start]+c.src[start+len(c.script):])
+	}
+
+	var scripts = make(map[*component]string)
+	for _, c := range used {
+		scripts[c] = c.script
+	}
+	var removed int = 0
+	for {
+		var words = make(map[string]int)
+		for word, n := range pageWords {
+			words[word] = n
+		}
+		for _, c := range used {
+			countWords(words, scripts[c])
+		}
+
+		var changed bool = false
+		for _, c := range used {
+			var safelist []string = componentSafelist(c)
+			var unused []string
+			for _, fn := range lib.JSFunctions(scripts[c]) {
+				// The declaration itself is one of the words
+				if fn.TopLevel && fn.Kind != "method" && words[fn.Name] <= 1 &&
+					!safelisted(safelist, fn.Name) {
+					unused = append(unused, fn.Name)
+				}
+			}
+			if len(unused) == 0 {
+				continue
+			}
+			script := lib.JSRemoveFunctions(scripts[c], unused)
+			if script != scripts[c] {
+				removed += len(lib.JSFunctions(scripts[c])) -
+					len(lib.JSFunctions(script))
+				scripts[c] = script
+				changed = true
+			}
+		}
+		if !changed {
+			return scripts, removed
+		}
+	}
+}
+
+// Adds how many times each word (each run of letters, digits, "_" and "$",
+// which covers every JavaScript identifier) appears in src to counts.
+func countWords(counts map[string]int, src string) {
+	for _, word := range strings.FieldsFunc(src, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' &&
+			r != '$'
+	}) {
+		counts[word]++
+	}
+}
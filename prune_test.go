@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Returns a component made from the source of a .webes file.
+func testComponent(name string, src string) *component {
+	var c = &component{name: name, src: src,
+		offsets: make(map[string]int)}
+	_, _, rest := cutSection(src, "template")
+	c.script, c.offsets["script"], _ = cutSection(rest, "script")
+	return c
+}
+
+func TestPruneScripts(t *testing.T) {
+	project = &Project{config: defaultConfig()}
+	var tests = []struct {
+		name  string
+		src   string   // of the component
+		pages []string // the sources of the pages
+		kept  []string // functions that have to be kept
+		gone  []string // functions that have to be removed
+	}{
+		{"unused", "<script>\nfunction a() {}\nfunction b() {}\nb()\n" +
+			"</script>", nil, []string{"b"}, []string{"a"}},
+		{"template literal", "<script>\nfunction item(n) {}\n" +
+			"el.innerHTML = `<li>${item(1)}</li>`\n</script>", nil,
+			[]string{"item"}, nil},
+		{"handler", "<template><button onclick=\"open()\"></button>" +
+			"</template>\n<script>\nfunction open() {}\n</script>", nil,
+			[]string{"open"}, nil},
+		{"page", "<script>\nfunction greet() {}\n</script>",
+			[]string{"<button onclick=\"greet()\"></button>"},
+			[]string{"greet"}, nil},
+		{"string", "<script>\nfunction go() {}\nwindow['go']()\n" +
+			"</script>", nil, []string{"go"}, nil},
+		{"chain", "<script>\nfunction a() { b() }\nfunction b() {}\n" +
+			"</script>", nil, nil, []string{"a", "b"}},
+		{"safelist", "<script>\n// webes-safelist: keep*\n" +
+			"function keepMe() {}\n</script>", nil, []string{"keepMe"},
+			nil},
+	}
+	for _, test := range tests {
+		var c *component = testComponent("_test", test.src)
+		scripts, _ := pruneScripts([]*component{c}, test.pages, nil)
+		for _, name := range test.kept {
+			if !strings.Contains(scripts[c], "function "+name) {
+				t.Errorf("%s: %s was removed:\n%s", test.name, name,
+					scripts[c])
+			}
+		}
+		for _, name := range test.gone {
+			if strings.Contains(scripts[c], "function "+name) {
+				t.Errorf("%s: %s was kept:\n%s", test.name, name, scripts[c])
+			}
+		}
+	}
+}
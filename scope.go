@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"      // Used for formatting scope identifiers
+	"hash/fnv" // Used for hashing component names into scope identifiers
+	"strings"  // Used for string manipulation
+	"unicode"  // Used for classifying characters of script namespaces
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// Returns the attribute that marks the elements of c's template, e.g.
+// "data-w-1a2b3c4d". It's derived from the component's name, so it stays the
+// same from one build to the next.
+func scopeAttr(c *component) string {
+	h := fnv.New32a()
+	h.Write([]byte(c.name))
+	return fmt.Sprintf("data-w-%08x", h.Sum32())
+}
+
+// Adds attr to every element in template. Components used by the template
+// are left alone, as they're marked with their own attribute once expanded.
+func scopeTemplate(template *lib.HTMLNode, attr string,
+	components map[string]*component) {
+	template.Walk(func(n *lib.HTMLNode) bool {
+		if n.Type != lib.HTMLElementNode || n.Is("script") || n.Is("style") {
+			return true
+		}
+		if _, ok, _ := lookupComponent(n, components); ok {
+			return true
+		}
+		n.Attrs = append([]*lib.HTMLAttr{{Name: attr}}, n.Attrs...)
+		return true
+	})
+}
+
+// Rewrites every selector in style so that it only matches elements marked
+// with attr (see scopeTemplate()). @media, @supports and other grouping rules
+// are scoped recursively, and @keyframes are renamed so that they can't clash
+// with another component's animations.
+func scopeStyle(style string, attr string) string {
+	var rules []*lib.CSSRule = lib.ParseCSS(style)
+
+	// Every @keyframes name gets the same suffix as the scope attribute
+	var keyframes = make(map[string]string)
+	collectKeyframes(rules, strings.TrimPrefix(attr, "data-w-"), keyframes)
+
+	scopeRules(rules, attr, keyframes)
+	return lib.RenderCSS(rules)
+}
+
+func collectKeyframes(rules []*lib.CSSRule, suffix string,
+	keyframes map[string]string) {
+	lib.WalkCSS(rules, func(rule *lib.CSSRule) {
+		if rule.IsKeyframes() && rule.Params != "" {
+			keyframes[rule.Params] = rule.Params + "-" + suffix
+		}
+	})
+}
+
+func scopeRules(rules []*lib.CSSRule, attr string,
+	keyframes map[string]string) {
+	for _, rule := range rules {
+		switch {
+		case rule.IsKeyframes():
+			if renamed, ok := keyframes[rule.Params]; ok {
+				rule.Params = renamed
+				rule.Prelude = "@" + rule.Name + " " + renamed
+			}
+		case rule.Name != "":
+			// @media, @supports, ... hold rules that need scoping, while the
+			// likes of @font-face and @import are left as they are.
+			scopeRules(rule.Rules, attr, keyframes)
+		default:
+			for _, sel := range rule.Selectors {
+				scopeSelector(sel, attr)
+			}
+			renameAnimations(rule.Declarations, keyframes)
+			scopeRules(rule.Rules, attr, keyframes)
+		}
+	}
+}
+
+// Adds `[attr]` to the last compound selector of sel, in front of any
+// pseudo-classes or pseudo-elements, so that
+// `.list > li:hover::after` becomes `.list > li[attr]:hover::after`.
+func scopeSelector(sel *lib.CSSSelector, attr string) {
+	if len(sel.Compounds) == 0 {
+		return
+	}
+	var compound *lib.CSSCompound = sel.Compounds[len(sel.Compounds)-1]
+
+	var insertAt int = len(compound.Simple)
+	for i, simple := range compound.Simple {
+		if simple.Type == lib.CSSPseudoClass ||
+			simple.Type == lib.CSSPseudoElement {
+			insertAt = i
+			break
+		}
+	}
+	var scope = &lib.CSSSimpleSelector{Type: lib.CSSAttributeSelector,
+		Name: attr, Raw: "[" + attr + "]"}
+	var simple []*lib.CSSSimpleSelector
+	simple = append(simple, compound.Simple[:insertAt]...)
+	simple = append(simple, scope)
+	compound.Simple = append(simple, compound.Simple[insertAt:]...)
+}
+
+// Renames the @keyframes used by any animation or animation-name declaration
+// among declarations.
+func renameAnimations(declarations []*lib.CSSDeclaration,
+	keyframes map[string]string) {
+	if len(keyframes) == 0 {
+		return
+	}
+
+	for _, decl := range declarations {
+		property := strings.TrimPrefix(strings.ToLower(decl.Property),
+			"-webkit-")
+		if property != "animation" && property != "animation-name" {
+			continue
+		}
+
+		// Replace every identifier in the value that names a @keyframes
+		var value string = decl.Value
+		var out strings.Builder
+		for j := 0; j < len(value); {
+			k := j
+			for k < len(value) && isCSSIdentChar(value[k]) {
+				k++
+			}
+			if k == j {
+				out.WriteByte(value[j])
+				j++
+				continue
+			}
+			if renamed, ok := keyframes[value[j:k]]; ok {
+				out.WriteString(renamed)
+			} else {
+				out.WriteString(value[j:k])
+			}
+			j = k
+		}
+		decl.Value = out.String()
+	}
+}
+
+func isCSSIdentChar(c byte) bool {
+	return c == '-' || c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+// Returns the property of `window.__webes` that holds the top-level bindings
+// of c's script, e.g. "_helloWorld". Characters that can't be part of an
+// identifier are written as "$" and their hex code, and so is "$" itself, so
+// that no two components end up with the same property: forms/_input becomes
+// "forms$2f_input", while forms__input stays as it is.
+func scriptNamespace(c *component) string {
+	var ns strings.Builder
+	for i := 0; i < len(c.name); i++ {
+		var b byte = c.name[i]
+		if b == '_' || b >= 0x80 || unicode.IsLetter(rune(b)) ||
+			(i > 0 && unicode.IsDigit(rune(b))) {
+			ns.WriteByte(b)
+		} else {
+			fmt.Fprintf(&ns, "$%02x", b)
+		}
+	}
+	return ns.String()
+}
+
+// Wraps script, c's (pruned) script, in a function so that its top-level
+// bindings can't collide with those of other components or the page itself.
+// The bindings are then exposed through `window.__webes[scriptNamespace(c)]`,
+// which is how the event handler attributes of c's template reach them (see
+// scopeHandlers()).
+func scopeScript(c *component, script string) string {
+	var ns string = scriptNamespace(c)
+	var out strings.Builder
+
+	out.WriteString("window.__webes = window.__webes || {};\n")
+	out.WriteString("window.__webes." + ns + " = (function () {\n")
+	out.WriteString(strings.TrimSpace(script) + "\n")
+	out.WriteString("return {\n")
+	var exported []string
+	for _, decl := range lib.JSTopLevelDeclarations(script) {
+		if contains(exported, decl.Name) {
+			continue
+		}
+		exported = append(exported, decl.Name)
+		out.WriteString("\tget " + decl.Name + "() { return " + decl.Name +
+			"; },\n")
+		if decl.Kind == "let" || decl.Kind == "var" {
+			out.WriteString("\tset " + decl.Name + "(value) { " + decl.Name +
+				" = value; },\n")
+		}
+	}
+	out.WriteString("};\n})();\n")
+	return out.String()
+}
+
+// Rewrites the event handler attributes (onclick="...", ...) of every element
+// in template so that the top-level bindings of c's script that they use are
+// looked up in c's namespace, e.g. onclick="greet()" becomes
+// onclick="__webes._helloWorld.greet()".
+func scopeHandlers(template *lib.HTMLNode, c *component,
+	components map[string]*component) {
+	var names []string
+	for _, decl := range lib.JSTopLevelDeclarations(c.script) {
+		names = append(names, decl.Name)
+	}
+	if len(names) == 0 {
+		return
+	}
+	var prefix string = "__webes." + scriptNamespace(c) + "."
+
+	template.Walk(func(n *lib.HTMLNode) bool {
+		// The attributes of components belong to the component, not to c
+		if _, ok, _ := lookupComponent(n, components); ok {
+			return true
+		}
+		for _, attr := range n.Attrs {
+			if isEventHandler(attr.Name) {
+				value := namespaceIdents(attr.Value, names, prefix)
+				if value != attr.Value {
+					attr.SetValue(value)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// Prefixes every identifier in the JavaScript code src that refers to one of
+// names (and isn't a property, as in `obj.name`) with prefix.
+func namespaceIdents(src string, names []string, prefix string) string {
+	var out strings.Builder
+	var pos int = 0
+	var prev string
+
+	for _, t := range lib.LexJS(src) {
+		if t.Type == lib.JSComment {
+			continue
+		}
+		if t.Type == lib.JSIdent && contains(names, t.Value) &&
+			prev != "." && prev != "?." {
+			out.WriteString(src[pos:t.Pos.Offset] + prefix)
+			pos = t.Pos.Offset
+		}
+		prev = t.Value
+	}
+	out.WriteString(src[pos:])
+	return out.String()
+}
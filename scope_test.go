@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestScriptNamespace(t *testing.T) {
+	var tests = []struct {
+		name string
+		want string
+	}{
+		{"_helloWorld", "_helloWorld"},
+		{"forms/_input", "forms$2f_input"},
+		{"forms__input", "forms__input"},
+		{"hero-banner", "hero$2dbanner"},
+		{"$x", "$24x"},
+		{"2col", "$32col"},
+		{"grid/2col", "grid$2f2col"},
+	}
+	var seen = make(map[string]string)
+	for _, test := range tests {
+		var got string = scriptNamespace(&component{name: test.name})
+		if got != test.want {
+			t.Errorf("scriptNamespace(%q) = %q, want %q", test.name, got,
+				test.want)
+		}
+		if other, ok := seen[got]; ok {
+			t.Errorf("%q and %q share the namespace %q", other, test.name,
+				got)
+		}
+		seen[got] = test.name
+	}
+}
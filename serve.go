@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"           // Used for writing server-sent events
+	"net"           // Used for joining the host and port
+	"net/http"      // Used for serving dist/
+	"os"            // Used for reading files
+	"path"          // Used for cleaning request paths
+	"path/filepath" // Used for building file paths
+	"strconv"       // Used for number to string conversions
+	"strings"       // Used for string manipulation
+	"sync"          // Used for keeping track of live-reload clients
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// The path that live-reload clients listen on for changes.
+const liveReloadPath = "/__webes/livereload"
+
+// The script injected into every HTML page that webes_serve serves, which
+// reloads the page whenever the project is rebuilt.
+const liveReloadScript = `<script>
+(function () {
+	var source = new EventSource("` + liveReloadPath + `");
+	source.onmessage = function () { location.reload(); };
+})();
+</script>
+`
+
+// The content types of the files that dist/ holds, so that they don't depend
+// on the system's MIME database.
+var contentTypes = map[string]string{
+	".html":  "text/html; charset=utf-8",
+	".htm":   "text/html; charset=utf-8",
+	".css":   "text/css; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+	".mjs":   "text/javascript; charset=utf-8",
+	".json":  "application/json",
+	".map":   "application/json",
+	".txt":   "text/plain; charset=utf-8",
+	".xml":   "application/xml",
+	".png":   "image/png",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".gif":   "image/gif",
+	".svg":   "image/svg+xml",
+	".webp":  "image/webp",
+	".avif":  "image/avif",
+	".ico":   "image/x-icon",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".mp4":   "video/mp4",
+	".webm":  "video/webm",
+	".mp3":   "audio/mpeg",
+	".pdf":   "application/pdf",
+}
+
+// Keeps track of the browsers that are waiting to be told to reload.
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// Tells every connected browser to reload.
+func (lr *liveReload) broadcast() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+			// A reload is already on its way to this client
+		}
+	}
+}
+
+// Holds a server-sent event stream open until the client goes away, sending
+// an event every time broadcast() is called.
+func (lr *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported",
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+
+	var client = make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[client] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Builds the project and serves dist/ over HTTP, rebuilding it (and reloading
+// any open pages) whenever something within dev/ changes.
+// Callable via `webes serve [--host=localhost] [--port=8080]`
+func webes_serve(call *commandCall) error {
+	lib.FmtPrint("Building Project", "header", "info")
+	var cache = &buildCache{}
+	if err := buildProject(cache, nil, ""); err != nil {
+		// Whatever was built last is served until the error is fixed
+		lib.FmtPrint(err.Error(), "error")
+	}
+
+	var reload = &liveReload{clients: make(map[chan struct{}]bool)}
+	go watchDir(devPath(), watchInterval, watchQuiet, func(changed []string) {
+		if err := buildProject(cache, changed, ""); err != nil {
+			lib.FmtPrint(err.Error(), "error")
+			return
+		}
+		reload.broadcast()
+	})
+
+	var mux = http.NewServeMux()
+	mux.Handle(liveReloadPath, reload)
+	mux.HandleFunc("/", serveDist)
+
+	var addr string = net.JoinHostPort(call.stringFlag("host"),
+		strconv.Itoa(call.intFlag("port")))
+	lib.FmtPrint("Serving dist/ at http://"+addr+"/ (press Ctrl+C to stop)",
+		"info")
+	return http.ListenAndServe(addr, mux)
+}
+
+// Serves the file within dist/ that the request is for, with the live-reload
+// script injected into HTML pages. Directories are served through their
+// index.html.
+func serveDist(w http.ResponseWriter, r *http.Request) {
+	// Cleaning the path as an absolute one keeps requests within dist/
+	var file string = distPath(filepath.FromSlash(
+		path.Clean("/" + r.URL.Path)))
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		file = filepath.Join(file, "index.html")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var contentType, ok = contentTypes[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		contentType = http.DetectContentType(data)
+	}
+	if strings.HasPrefix(contentType, "text/html") {
+		data = injectLiveReload(data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// Returns page with the live-reload script added right before its </body>,
+// or at its very end if it has none.
+func injectLiveReload(page []byte) []byte {
+	var html string = string(page)
+	for idx := len(html) - len("</body>"); idx >= 0; idx-- {
+		if strings.EqualFold(html[idx:idx+len("</body>")], "</body>") {
+			return []byte(html[:idx] + liveReloadScript + html[idx:])
+		}
+	}
+	return []byte(html + liveReloadScript)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"     // Used for building warnings
+	"strings" // Used for string manipulation
+
+	"webes/lib" // Used for various utility functions specific to webes
+)
+
+// Components mark where the content given between their tags goes with
+// <slot> elements:
+//
+//	<template>
+//		<div class="card">
+//			<h2><slot name="title">Untitled</slot></h2>
+//			<slot></slot>
+//		</div>
+//	</template>
+//
+// Elements given with slot="title" fill the slot of that name, and everything
+// else fills the default, unnamed one. Slots that nothing fills are replaced
+// by their own content instead:
+//
+//	<Card>
+//		<span slot="title">Hello</span>
+//		<p>Some text for the default slot.</p>
+//	</Card>
+
+// Returns the name of every slot that c's template declares, with "" for the
+// default slot.
+func (c *component) slots() []string {
+	var names []string
+	lib.ParseHTML(c.template).Walk(func(n *lib.HTMLNode) bool {
+		if n.Is("slot") && !contains(names, n.AttrValue("name")) {
+			names = append(names, n.AttrValue("name"))
+		}
+		return true
+	})
+	return names
+}
+
+// Splits the children of el, the element that uses a component, into the
+// slots that they fill, keyed by slot name, along with the names of those
+// slots in order of first use. The slot attribute is removed
+// from the elements that have one, and a <template slot="..."> is replaced by
+// its children. Whitespace and comments on their own don't fill the default
+// slot.
+func slotContent(el *lib.HTMLNode) (map[string][]*lib.HTMLNode, []string) {
+	var content = make(map[string][]*lib.HTMLNode)
+	var names []string
+	add := func(name string, nodes ...*lib.HTMLNode) {
+		if _, ok := content[name]; !ok {
+			names = append(names, name)
+		}
+		content[name] = append(content[name], nodes...)
+	}
+	for _, child := range el.Children {
+		name, ok := child.Attr("slot")
+		if child.Type != lib.HTMLElementNode || !ok {
+			add("", child)
+			continue
+		}
+		child.RemoveAttr("slot")
+		if child.Is("template") {
+			add(name.Value, child.Children...)
+		} else {
+			add(name.Value, child)
+		}
+	}
+	if !fillsSlot(content[""]) {
+		delete(content, "")
+		for i, name := range names {
+			if name == "" {
+				names = append(names[:i], names[i+1:]...)
+				break
+			}
+		}
+	}
+	return content, names
+}
+
+// Returns whether nodes hold anything but whitespace and comments.
+func fillsSlot(nodes []*lib.HTMLNode) bool {
+	for _, n := range nodes {
+		switch n.Type {
+		case lib.HTMLTextNode:
+			if strings.TrimSpace(n.Data) != "" {
+				return true
+			}
+		case lib.HTMLCommentNode:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Replaces every <slot> within template with the content that fills it, or
+// with the slot's own children if nothing does. Content is only placed once,
+// so a slot that's declared twice falls back to its own children the second
+// time.
+func fillSlots(template *lib.HTMLNode, content map[string][]*lib.HTMLNode) {
+	var slots []*lib.HTMLNode
+	template.Walk(func(n *lib.HTMLNode) bool {
+		if n.Is("slot") {
+			slots = append(slots, n)
+		}
+		return true
+	})
+
+	// Slots within the fallback content of another slot are filled first,
+	// so that it's complete by the time that it's needed.
+	for i := len(slots) - 1; i >= 0; i-- {
+		var name string = slots[i].AttrValue("name")
+		nodes, ok := content[name]
+		if !ok || !isFirstSlot(slots[:i], name) {
+			slots[i].ReplaceWith(slots[i].Children...)
+			continue
+		}
+		slots[i].ReplaceWith(nodes...)
+	}
+}
+
+// Returns whether none of slots is called name.
+func isFirstSlot(slots []*lib.HTMLNode, name string) bool {
+	for _, slot := range slots {
+		if slot.AttrValue("name") == name {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a problem for every slot that el, the element that uses c, fills
+// but that c doesn't declare, since whatever it's filled with would be lost.
+func slotProblems(c *component, el *lib.HTMLNode) []usageProblem {
+	var declared []string = c.slots()
+	var problems []usageProblem
+	var defaultReported bool = false
+	for _, child := range el.Children {
+		name, ok := child.Attr("slot")
+		if child.Type == lib.HTMLElementNode && ok {
+			if !contains(declared, name.Value) {
+				problems = append(problems, usageProblem{name.Pos,
+					len(name.Name), fmt.Sprintf("%s has no slot called %q",
+						c.name, name.Value), "unknown-slot", "warning"})
+			}
+			continue
+		}
+		if defaultReported || contains(declared, "") ||
+			!fillsSlot([]*lib.HTMLNode{child}) {
+			continue
+		}
+		var pos lib.Pos = child.Pos
+		var length int = len("<" + child.Name)
+		if child.Type == lib.HTMLTextNode {
+			// Pointing at the first line of the text rather than at the
+			// whitespace before it (only the offset of a position is used)
+			var text string = strings.TrimLeft(child.Data, " \t\r\n")
+			pos.Offset += len(child.Data) - len(text)
+			if idx := strings.IndexByte(text, '\n'); idx != -1 {
+				text = text[:idx]
+			}
+			length = len(strings.TrimSpace(text))
+		}
+		problems = append(problems, usageProblem{pos, length,
+			fmt.Sprintf("%s has no default <slot> for the content given to it",
+				c.name), "unknown-slot", "warning"})
+		defaultReported = true
+	}
+	return problems
+}
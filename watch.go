@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/fs"         // Used for walking dev/
+	"path/filepath" // Used for building file paths
+	"time"          // Used for polling
+)
+
+// The modification time and size of every file within a directory, keyed by
+// path.
+type dirSnapshot map[string]fileState
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Records the state of every file within dir. Files that can't be read are
+// left out, so that they show up as changed once they can be.
+func snapshotDir(dir string) dirSnapshot {
+	var snapshot = make(dirSnapshot)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		snapshot[path] = fileState{info.ModTime(), info.Size()}
+		return nil
+	})
+	return snapshot
+}
+
+// Returns every path that was added, removed, or modified between the
+// snapshots old and new.
+func (old dirSnapshot) changes(new dirSnapshot) []string {
+	var changed []string
+	for path, state := range new {
+		if prev, ok := old[path]; !ok || prev != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range old {
+		if _, ok := new[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// How often watchDir() polls for changes, and how long things need to stay
+// unchanged before the changes are acted upon. The latter keeps a burst of
+// saves (e.g. an editor writing several files at once) to a single rebuild.
+const watchInterval = 250 * time.Millisecond
+const watchQuiet = 200 * time.Millisecond
+
+// Polls dir every interval, and calls onChange with the paths that changed
+// once nothing has changed for at least quiet. Never returns.
+func watchDir(dir string, interval time.Duration, quiet time.Duration,
+	onChange func([]string)) {
+	var snapshot dirSnapshot = snapshotDir(dir)
+	var pending []string
+	var lastChange time.Time
+	for {
+		time.Sleep(interval)
+		next := snapshotDir(dir)
+		if changed := snapshot.changes(next); len(changed) > 0 {
+			for _, path := range changed {
+				if !contains(pending, path) {
+					pending = append(pending, path)
+				}
+			}
+			lastChange = time.Now()
+		} else if len(pending) > 0 && time.Since(lastChange) >= quiet {
+			onChange(pending)
+			pending = nil
+		}
+		snapshot = next
+	}
+}